@@ -0,0 +1,107 @@
+// Package archive exports the state of service center into a versioned archive,
+// and imports an archive into service center idempotently,
+// for example, for disaster recovery drills and migrating between clusters.
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the format version of the archives written by this package
+const Version = 1
+
+// Format is the encoding of an archive
+type Format string
+
+const (
+	// FormatJSON encodes the archive in JSON
+	FormatJSON Format = "json"
+	// FormatYAML encodes the archive in YAML
+	FormatYAML Format = "yaml"
+)
+
+// Archive is the state of service center
+type Archive struct {
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exportedAt"`
+	Services   []*Service `json:"services"`
+}
+
+// Service is a service and the resources belonging to it
+type Service struct {
+	MicroService *discovery.MicroService `json:"microService"`
+	// Schemas are the schemas with content
+	Schemas []*discovery.Schema      `json:"schemas,omitempty"`
+	Tags    map[string]string        `json:"tags,omitempty"`
+	Rules   []*discovery.ServiceRule `json:"rules,omitempty"`
+	// Providers are the services this service depends on
+	Providers []*discovery.MicroServiceKey `json:"providers,omitempty"`
+	// Instances are exported only if ExportOptions.Instances is set
+	Instances []*discovery.MicroServiceInstance `json:"instances,omitempty"`
+}
+
+// Write encodes the archive to w in the format
+func Write(w io.Writer, a *Archive, format Format) error {
+	b, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatJSON:
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case FormatYAML:
+		// the field names of the YAML are the same as JSON
+		var doc interface{}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("unknown archive format %q", format)
+}
+
+// Read decodes an archive in JSON or YAML
+func Read(r io.Reader) (*Archive, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b := content
+	if !json.Valid(bytes.TrimSpace(content)) {
+		// JSON is a subset of YAML, convert YAML to JSON to decode with the JSON field names
+		var doc interface{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("decode archive failed: %s", err.Error())
+		}
+		if b, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("decode archive failed: %s", err.Error())
+		}
+	}
+	var a Archive
+	if err := json.Unmarshal(b, &a); err != nil {
+		return nil, fmt.Errorf("decode archive failed: %s", err.Error())
+	}
+	if a.Version == 0 || a.Version > Version {
+		return nil, fmt.Errorf("unsupported archive version %d, the supported version is %d", a.Version, Version)
+	}
+	for i, s := range a.Services {
+		if s == nil || s.MicroService == nil {
+			return nil, fmt.Errorf("service %d of the archive has no microService", i)
+		}
+	}
+	return &a, nil
+}
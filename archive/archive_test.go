@@ -0,0 +1,103 @@
+package archive_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+	"github.com/go-chassis/sc-client/archive"
+	"github.com/go-chassis/sc-client/internal/scfake"
+)
+
+const schema = `swagger: "2.0"
+info:
+  title: hello
+  version: 1.0.0
+paths:
+  /hello:
+    get:
+      responses:
+        "200":
+          description: ok
+`
+
+func newClient(t *testing.T, s *scfake.Server) *sc.Client {
+	c, err := sc.NewClient(sc.Options{Endpoints: []string{s.Addr()}})
+	assert.NoError(t, err)
+	return c
+}
+
+func TestExportImport(t *testing.T) {
+	source := scfake.New()
+	defer source.Close()
+	provider := source.AddService(&discovery.MicroService{AppId: "default", ServiceName: "provider", Version: "1.0.0"})
+	consumer := source.AddService(&discovery.MicroService{AppId: "default", ServiceName: "consumer", Version: "1.0.0",
+		Properties: map[string]string{"owner": "team"}})
+	source.AddService(&discovery.MicroService{AppId: "default", ServiceName: "SERVICECENTER", Version: "2.0.0"})
+	source.Lock()
+	source.Services[provider].Schemas["hello"] = &discovery.Schema{SchemaId: "hello", Schema: schema, Summary: "s1"}
+	source.Services[provider].Tags["zone"] = "az1"
+	source.Services[provider].Rules = []*discovery.ServiceRule{{RuleId: "r", RuleType: "BLACK", Attribute: "ServiceName", Pattern: "bad"}}
+	source.Services[provider].Instances = []*discovery.MicroServiceInstance{{InstanceId: "i", ServiceId: provider, HostName: "h"}}
+	source.Services[consumer].Providers = []*discovery.MicroServiceKey{{AppId: "default", ServiceName: "provider", Version: "1.0.0"}}
+	source.Unlock()
+
+	c := newClient(t, source)
+	defer c.Close()
+	a, err := archive.Export(c, archive.ExportOptions{Instances: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(a.Services))
+
+	for _, format := range []archive.Format{archive.FormatJSON, archive.FormatYAML} {
+		var buf bytes.Buffer
+		assert.NoError(t, archive.Write(&buf, a, format))
+		read, err := archive.Read(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, len(a.Services), len(read.Services))
+		assert.Equal(t, a.Services[1].Schemas[0].Schema, read.Services[1].Schemas[0].Schema)
+	}
+
+	target := scfake.New()
+	defer target.Close()
+	// the default environment of the client does not apply to the services archived without environment
+	tc, err := sc.NewClient(sc.Options{Endpoints: []string{target.Addr()}, Environment: "testing"})
+	assert.NoError(t, err)
+	defer tc.Close()
+
+	report, err := archive.Import(tc, a, archive.ImportOptions{DryRun: true, Instances: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "+ service default/consumer/1.0.0\n"+
+		"+ service default/provider/1.0.0\n"+
+		"+ schema default/provider/1.0.0 hello\n"+
+		"+ tag default/provider/1.0.0 zone\n"+
+		"+ rule default/provider/1.0.0 BLACK ServiceName bad\n"+
+		"+ instance default/provider/1.0.0 i\n"+
+		"+ dependency default/consumer/1.0.0 default/provider/1.0.0\n", report.String())
+	target.Lock()
+	assert.Empty(t, target.Requests)
+	target.Unlock()
+
+	report, err = archive.Import(tc, a, archive.ImportOptions{Instances: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(report.Changes))
+	target.Lock()
+	imported := target.Find("default", "provider", "1.0.0", "")
+	if assert.NotNil(t, imported) {
+		assert.Equal(t, schema, imported.Schemas["hello"].Schema)
+		assert.Equal(t, "az1", imported.Tags["zone"])
+		assert.Equal(t, 1, len(imported.Rules))
+		assert.Equal(t, 1, len(imported.Instances))
+	}
+	assert.Equal(t, "team", target.Find("default", "consumer", "1.0.0", "").MicroService.Properties["owner"])
+	target.Unlock()
+
+	report, err = archive.Import(tc, a, archive.ImportOptions{Instances: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "no changes\n", report.String())
+
+	_, err = archive.Read(bytes.NewBufferString("version: 2\nservices: []\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+
+	"github.com/go-chassis/sc-client"
+)
+
+// serviceCenterName is the name of the service registered by service center itself, it is not exported
+const serviceCenterName = "SERVICECENTER"
+
+// ExportOptions is the options of Export
+type ExportOptions struct {
+	// Instances exports the instances, they expire in the target cluster if nobody sends heartbeats for them
+	Instances bool
+}
+
+// Export dumps the services with their schemas, tags, rules and dependencies, and optionally instances
+func Export(c *sc.Client, opt ExportOptions) (*Archive, error) {
+	details := []string{string(sc.GovernSchemas), string(sc.GovernTags), string(sc.GovernRules), string(sc.GovernDependencies)}
+	if opt.Instances {
+		details = append(details, string(sc.GovernInstances))
+	}
+	services, err := c.GetAllResources(strings.Join(details, ","))
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{Version: Version, ExportedAt: time.Now().UTC()}
+	for _, detail := range services {
+		if detail == nil || detail.MicroService == nil || detail.MicroService.ServiceName == serviceCenterName {
+			continue
+		}
+		s := &Service{
+			MicroService: detail.MicroService,
+			Schemas:      detail.SchemaInfos,
+			Tags:         detail.Tags,
+			Rules:        detail.Rules,
+		}
+		for _, provider := range detail.Providers {
+			s.Providers = append(s.Providers, keyOf(provider))
+		}
+		sort.Slice(s.Providers, func(i, j int) bool {
+			return keyString(s.Providers[i]) < keyString(s.Providers[j])
+		})
+		if opt.Instances {
+			s.Instances = detail.Instances
+		}
+		a.Services = append(a.Services, s)
+	}
+	sort.Slice(a.Services, func(i, j int) bool {
+		return keyString(keyOf(a.Services[i].MicroService)) < keyString(keyOf(a.Services[j].MicroService))
+	})
+	return a, nil
+}
+
+func keyOf(ms *discovery.MicroService) *discovery.MicroServiceKey {
+	return &discovery.MicroServiceKey{
+		Environment: ms.Environment,
+		AppId:       ms.AppId,
+		ServiceName: ms.ServiceName,
+		Version:     ms.Version,
+	}
+}
+
+// keyString is like default/hello/1.0.0, the environment is appended if it is not empty
+func keyString(key *discovery.MicroServiceKey) string {
+	s := key.AppId + "/" + key.ServiceName + "/" + key.Version
+	if key.Environment != "" {
+		s += "@" + key.Environment
+	}
+	return s
+}
@@ -0,0 +1,302 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-chassis/cari/discovery"
+
+	"github.com/go-chassis/sc-client"
+)
+
+// the actions of changes
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+)
+
+// the kinds of resources changed
+const (
+	KindService    = "service"
+	KindSchema     = "schema"
+	KindTag        = "tag"
+	KindRule       = "rule"
+	KindDependency = "dependency"
+	KindInstance   = "instance"
+)
+
+// ImportOptions is the options of Import
+type ImportOptions struct {
+	// DryRun reports the changes without applying them
+	DryRun bool
+	// Instances imports the instances in the archive
+	Instances bool
+}
+
+// Change is a change made or to be made by Import
+type Change struct {
+	Action string
+	Kind   string
+	// Service is the key of the service, like default/hello/1.0.0
+	Service string
+	// Name identifies the resource in the service, like schema id, tag key and provider key
+	Name string
+}
+
+func (c *Change) String() string {
+	sign := "+"
+	if c.Action == ActionUpdate {
+		sign = "~"
+	}
+	s := fmt.Sprintf("%s %s %s", sign, c.Kind, c.Service)
+	if c.Name != "" {
+		s += " " + c.Name
+	}
+	return s
+}
+
+// Report is the result of Import
+type Report struct {
+	DryRun  bool
+	Changes []*Change
+}
+
+// String returns the changes in lines, + for creating and ~ for updating
+func (r *Report) String() string {
+	if len(r.Changes) == 0 {
+		return "no changes\n"
+	}
+	var b strings.Builder
+	for _, c := range r.Changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+type importer struct {
+	c      *sc.Client
+	opt    ImportOptions
+	report *Report
+}
+
+// imported is a service after being imported, id is empty if it is to be created in dry run
+type imported struct {
+	service *Service
+	id      string
+	detail  *discovery.ServiceDetail
+}
+
+// Import replays the archive into service center, the resources which exist and are the same are skipped,
+// so importing the same archive again makes no changes. Nothing is deleted from service center.
+// If an error occurs, the report contains the changes applied before it.
+func Import(c *sc.Client, a *Archive, opt ImportOptions) (*Report, error) {
+	im := &importer{c: c, opt: opt, report: &Report{DryRun: opt.DryRun}}
+	done := make([]*imported, 0, len(a.Services))
+	for _, s := range a.Services {
+		i, err := im.importService(s)
+		if err != nil {
+			return im.report, fmt.Errorf("import %s failed: %w", keyString(keyOf(s.MicroService)), err)
+		}
+		done = append(done, i)
+	}
+	// the providers are imported before the dependencies
+	for _, i := range done {
+		if err := im.importDependencies(i); err != nil {
+			return im.report, fmt.Errorf("import dependencies of %s failed: %w", keyString(keyOf(i.service.MicroService)), err)
+		}
+	}
+	return im.report, nil
+}
+
+func (im *importer) add(action, kind string, s *Service, name string) {
+	im.report.Changes = append(im.report.Changes, &Change{
+		Action:  action,
+		Kind:    kind,
+		Service: keyString(keyOf(s.MicroService)),
+		Name:    name,
+	})
+}
+
+func (im *importer) importService(s *Service) (*imported, error) {
+	ms := s.MicroService
+	id, err := im.c.GetMicroServiceID(ms.AppId, ms.ServiceName, ms.Version, ms.Environment, sc.WithExactEnvironment())
+	if err != nil {
+		return nil, err
+	}
+	i := &imported{service: s, id: id}
+	if id == "" {
+		im.add(ActionCreate, KindService, s, "")
+		if !im.opt.DryRun {
+			copied := *ms
+			if i.id, err = im.c.RegisterService(&copied); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		details := []sc.GovernOption{sc.GovernTags, sc.GovernRules, sc.GovernDependencies}
+		if im.opt.Instances {
+			details = append(details, sc.GovernInstances)
+		}
+		if i.detail, err = im.c.GetServiceDetail(id, details); err != nil {
+			return nil, err
+		}
+		if len(ms.Properties) != 0 && i.detail.MicroService != nil && !reflect.DeepEqual(ms.Properties, i.detail.MicroService.Properties) {
+			im.add(ActionUpdate, KindService, s, "properties")
+			if !im.opt.DryRun {
+				if _, err := im.c.UpdateMicroServiceProperties(id, &discovery.MicroService{Properties: ms.Properties}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if i.detail == nil {
+		i.detail = &discovery.ServiceDetail{}
+	}
+	if err := im.importSchemas(i); err != nil {
+		return nil, err
+	}
+	if err := im.importTags(i); err != nil {
+		return nil, err
+	}
+	if err := im.importRules(i); err != nil {
+		return nil, err
+	}
+	if im.opt.Instances {
+		if err := im.importInstances(i); err != nil {
+			return nil, err
+		}
+	}
+	return i, nil
+}
+
+func (im *importer) importSchemas(i *imported) error {
+	summaries := make(map[string]string)
+	if i.id != "" {
+		existing, err := im.c.ListSchemas(i.id)
+		if err != nil {
+			return err
+		}
+		for _, schema := range existing {
+			summaries[schema.SchemaId] = schema.Summary
+		}
+	}
+	for _, schema := range i.service.Schemas {
+		summary, ok := summaries[schema.SchemaId]
+		// AddSchemas sets the summary to the sha256 of the content, which may differ from the one in the archive
+		if ok && (summary == schema.Summary || summary == fmt.Sprintf("%x", sha256.Sum256([]byte(schema.Schema)))) {
+			continue
+		}
+		action := ActionCreate
+		if ok {
+			action = ActionUpdate
+		}
+		im.add(action, KindSchema, i.service, schema.SchemaId)
+		if im.opt.DryRun {
+			continue
+		}
+		if err := im.c.AddSchemas(i.id, schema.SchemaId, schema.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *importer) importTags(i *imported) error {
+	changed := make(map[string]string)
+	keys := make([]string, 0, len(i.service.Tags))
+	for k := range i.service.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := i.detail.Tags[k]
+		if ok && v == i.service.Tags[k] {
+			continue
+		}
+		action := ActionCreate
+		if ok {
+			action = ActionUpdate
+		}
+		im.add(action, KindTag, i.service, k)
+		changed[k] = i.service.Tags[k]
+	}
+	if im.opt.DryRun || len(changed) == 0 {
+		return nil
+	}
+	return im.c.AddTags(i.id, changed)
+}
+
+func ruleKey(ruleType, attribute, pattern string) string {
+	return ruleType + " " + attribute + " " + pattern
+}
+
+func (im *importer) importRules(i *imported) error {
+	existing := make(map[string]bool, len(i.detail.Rules))
+	for _, rule := range i.detail.Rules {
+		existing[ruleKey(rule.RuleType, rule.Attribute, rule.Pattern)] = true
+	}
+	var rules []*discovery.AddOrUpdateServiceRule
+	for _, rule := range i.service.Rules {
+		key := ruleKey(rule.RuleType, rule.Attribute, rule.Pattern)
+		if existing[key] {
+			continue
+		}
+		im.add(ActionCreate, KindRule, i.service, key)
+		rules = append(rules, &discovery.AddOrUpdateServiceRule{
+			RuleType:    rule.RuleType,
+			Attribute:   rule.Attribute,
+			Pattern:     rule.Pattern,
+			Description: rule.Description,
+		})
+	}
+	if im.opt.DryRun || len(rules) == 0 {
+		return nil
+	}
+	return im.c.AddRules(i.id, rules)
+}
+
+func (im *importer) importDependencies(i *imported) error {
+	existing := make(map[string]bool, len(i.detail.Providers))
+	for _, provider := range i.detail.Providers {
+		existing[keyString(keyOf(provider))] = true
+	}
+	var providers []*discovery.MicroServiceKey
+	for _, provider := range i.service.Providers {
+		key := keyString(provider)
+		if existing[key] {
+			continue
+		}
+		im.add(ActionCreate, KindDependency, i.service, key)
+		providers = append(providers, provider)
+	}
+	if im.opt.DryRun || len(providers) == 0 {
+		return nil
+	}
+	return im.c.AddDependencies(keyOf(i.service.MicroService), providers)
+}
+
+func (im *importer) importInstances(i *imported) error {
+	existing := make(map[string]bool, len(i.detail.Instances))
+	for _, instance := range i.detail.Instances {
+		existing[instance.InstanceId] = true
+	}
+	for _, instance := range i.service.Instances {
+		if existing[instance.InstanceId] {
+			continue
+		}
+		im.add(ActionCreate, KindInstance, i.service, instance.InstanceId)
+		if im.opt.DryRun {
+			continue
+		}
+		copied := *instance
+		copied.ServiceId = i.id
+		if _, err := im.c.RegisterMicroServiceInstance(&copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -32,6 +32,7 @@ const (
 	BatchInstancePath      = "/instances/action"
 	SchemaPath             = "/schemas"
 	HeartbeatPath          = "/heartbeat"
+	BatchHeartbeatPath     = "/heartbeats"
 	ExistencePath          = "/existence"
 	WatchPath              = "/watcher"
 	StatusPath             = "/status"
@@ -49,6 +50,9 @@ const (
 	DefaultTokenExpiration = 10 * time.Hour
 	HeaderRevision         = "X-Resource-Revision"
 	EnvProjectID           = "CSE_PROJECT_ID"
+
+	// DefaultSyncEndpointsInterval is the default interval of Options.AutoSyncEndpoints
+	DefaultSyncEndpointsInterval = 30 * time.Second
 )
 
 // Define variables for the client
@@ -63,11 +67,19 @@ var (
 	ErrNotModified = errors.New("instance is not changed since last query")
 	// ErrMicroServiceExists means service is registered
 	ErrMicroServiceExists = errors.New("micro-service already exists")
+	// ErrSchemaListMismatch means the registered service declares a different schema list
+	ErrSchemaListMismatch = errors.New("schema list mismatches the registered micro-service")
 	// ErrMicroServiceNotExists means service is not exists
 	ErrMicroServiceNotExists = errors.New("micro-service does not exist")
+	// ErrMicroServiceInstanceNotExists means instance is not exists
+	ErrMicroServiceInstanceNotExists = errors.New("micro-service instance does not exist")
+	// ErrBatchHeartbeatNotSupported means service-center does not provide the batch heartbeat API
+	ErrBatchHeartbeatNotSupported = errors.New("batch heartbeat is not supported")
 	// ErrEmptyCriteria means you gave an empty list of criteria
 	ErrEmptyCriteria = errors.New("batch find criteria is empty")
 	ErrNil           = errors.New("input is nil")
+
+	errWebsocketUpgradeRefused = errors.New("websocket upgrade is refused")
 )
 
 // Client communicate to Service-Center
@@ -80,16 +92,43 @@ type Client struct {
 	// addresspool mutex
 	poolMutex sync.Mutex
 	wsDialer  *websocket.Dialer
+	// signRequest signs the websocket handshakes the same as the http requests
+	signRequest func(*http.Request) error
+	// certs reloads the files of Options.TLS
+	certs *certReloader
+	// cfgMutex guards opt, client, protocol, wsDialer, signRequest, certs and pool, which are replaced by Reset
+	cfgMutex sync.RWMutex
 	// record the websocket connection with the service center
 	conns map[string]*websocket.Conn
 	pool  *addresspool.Pool
+	// the address list last applied to the pool by auto sync
+	syncedEndpoints []string
+	// the healthy cluster members discovered by the last successful auto sync, guarded by poolMutex
+	discoveredEndpoints []string
+	stopCh              chan struct{}
+	closeOnce           sync.Once
+	outlier             *OutlierDetector
+	hedger              *hedger
+	flights             *flightGroup
+	limiters            map[OperationClass]*limiter
+	// registrationJitter delays the first registration once
+	registrationJitter time.Duration
+	jitterOnce         sync.Once
+	// the endpoints of the engine switched to by dual engine failover, guarded by poolMutex
+	activeEndpoints []string
+	peerMutex       sync.Mutex
+	peers           []*Peer
 }
 
 func (c *Client) dialWebsocket(url *url.URL) (*websocket.Conn, *http.Response, error) {
 	var err error
+	c.reloadRoots()
+	c.cfgMutex.RLock()
+	signRequest, wsDialer := c.signRequest, c.wsDialer
+	c.cfgMutex.RUnlock()
 	handshakeReq := &http.Request{Header: c.GetDefaultHeaders(), URL: url}
-	if c.opt.SignRequest != nil {
-		if err = c.opt.SignRequest(handshakeReq); err != nil {
+	if signRequest != nil {
+		if err = signRequest(handshakeReq); err != nil {
 			openlog.Error("sign websocket request failed" + err.Error())
 			return nil, nil, err
 		}
@@ -100,7 +139,34 @@ func (c *Client) dialWebsocket(url *url.URL) (*websocket.Conn, *http.Response, e
 		}
 	}
 
-	return c.wsDialer.Dial(url.String(), handshakeReq.Header)
+	return wsDialer.Dial(url.String(), handshakeReq.Header)
+}
+
+// options returns the options given to NewClient or the last Reset
+func (c *Client) options() Options {
+	c.cfgMutex.RLock()
+	defer c.cfgMutex.RUnlock()
+	return c.opt
+}
+
+func (c *Client) httpClient() *httpclient.Requests {
+	c.cfgMutex.RLock()
+	defer c.cfgMutex.RUnlock()
+	return c.client
+}
+
+func (c *Client) addressPool() *addresspool.Pool {
+	c.cfgMutex.RLock()
+	defer c.cfgMutex.RUnlock()
+	return c.pool
+}
+
+// wsScheme returns the scheme of websocket connections
+func (c *Client) wsScheme() string {
+	if c.options().EnableSSL {
+		return "wss"
+	}
+	return "ws"
 }
 
 type PeerStatusResp struct {
@@ -120,52 +186,116 @@ type URLParameter map[string]string
 
 // NewClient create a the service center client
 func NewClient(opt Options) (*Client, error) {
+	certs, err := opt.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
 	c := &Client{
 		opt:      opt,
+		certs:    certs,
 		watchers: make(map[string]bool),
 		conns:    make(map[string]*websocket.Conn),
+		stopCh:   make(chan struct{}),
 	}
 	options := c.buildClientOptions(opt)
-	var err error
 	c.client, err = httpclient.New(options)
 	if err != nil {
 		return nil, err
 	}
-	c.wsDialer = &websocket.Dialer{
-		TLSClientConfig: opt.TLSConfig,
-	}
-	c.protocol = "https"
-	if !c.opt.EnableSSL {
-		c.wsDialer = websocket.DefaultDialer
-		c.protocol = "http"
-	}
+	c.signRequest = options.SignRequest
+	c.wsDialer, c.protocol = newWebsocketDialer(opt)
 	// Update the API Base Path based on the project
 	c.updateAPIPath()
-	c.pool = addresspool.NewPool(opt.Endpoints, addresspool.Options{
+	c.pool = newAddressPool(opt.Endpoints, c.protocol)
+	if opt.OutlierDetection != nil {
+		c.outlier = NewOutlierDetector(*opt.OutlierDetection)
+	}
+	if opt.Hedging != nil {
+		c.hedger = newHedger(*opt.Hedging)
+	}
+	if opt.DeduplicateReads {
+		c.flights = newFlightGroup()
+	}
+	c.limiters = make(map[OperationClass]*limiter, len(opt.RateLimits))
+	for class, limit := range opt.RateLimits {
+		c.limiters[class] = newLimiter(limit)
+	}
+	c.registrationJitter = opt.RegistrationJitter
+	if opt.AutoSyncEndpoints {
+		go c.autoSyncEndpoints()
+	}
+	if opt.DualEngine != nil {
+		go c.watchEngines(*opt.DualEngine)
+	}
+	return c, nil
+}
+
+// newWebsocketDialer returns the websocket dialer and the http protocol of the options
+func newWebsocketDialer(opt Options) (*websocket.Dialer, string) {
+	if !opt.EnableSSL {
+		return websocket.DefaultDialer, "http"
+	}
+	return &websocket.Dialer{
+		TLSClientConfig: opt.TLSConfig,
+	}, "https"
+}
+
+func newAddressPool(endpoints []string, protocol string) *addresspool.Pool {
+	return addresspool.NewPool(endpoints, addresspool.Options{
 		HttpProbeOptions: &addresspool.HttpProbeOptions{
-			Protocol: c.protocol,
+			Protocol: protocol,
 			Path:     MSAPIPath + ReadinessPath,
 		},
 	})
-	return c, nil
 }
 
-// Reset the service center client
+// Reset applies the options to the client, like auth, TLS, timeout and endpoints,
+// it is safe to call while the client is in use, the requests in flight finish with the former options.
+// The watches and websocket heartbeats are re-established with the new options by their own goroutines.
+// AutoSyncEndpoints, DualEngine, OutlierDetection, Hedging, DeduplicateReads, RateLimits
+// and RegistrationJitter keep the settings given to NewClient.
 func (c *Client) Reset(opt Options) error {
-	c.poolMutex.Lock()
-	defer c.poolMutex.Unlock()
-	options := c.buildClientOptions(opt)
-	var err error
-	c.client, err = httpclient.New(options)
+	certs, err := opt.buildTLSConfig()
 	if err != nil {
 		return err
 	}
-	c.protocol = "https"
-	if !c.opt.EnableSSL {
-		c.wsDialer = websocket.DefaultDialer
-		c.protocol = "http"
+	options := c.buildClientOptions(opt)
+	client, err := httpclient.New(options)
+	if err != nil {
+		return err
 	}
-	c.pool.ResetAddress(opt.Endpoints)
+	wsDialer, protocol := newWebsocketDialer(opt)
+
+	c.poolMutex.Lock()
+	c.cfgMutex.Lock()
+	var stale *addresspool.Pool
+	if protocol != c.protocol {
+		// the probe protocol of a pool can not be changed
+		stale = c.pool
+		c.pool = newAddressPool(opt.Endpoints, protocol)
+	} else {
+		c.pool.ResetAddress(opt.Endpoints)
+	}
+	c.opt = opt
+	c.certs = certs
+	c.client = client
+	c.protocol = protocol
+	c.wsDialer = wsDialer
+	c.signRequest = options.SignRequest
+	c.cfgMutex.Unlock()
+	c.syncedEndpoints = nil
+	c.discoveredEndpoints = nil
+	c.activeEndpoints = nil
+	c.poolMutex.Unlock()
+	if stale != nil {
+		stale.Close()
+	}
+
+	// the peers are reported again by the new endpoints
+	c.peerMutex.Lock()
+	c.peers = nil
+	c.peerMutex.Unlock()
+	c.reconnectWebsockets()
 	return nil
 }
 
@@ -187,25 +317,41 @@ func (c *Client) buildClientOptions(opt Options) *httpclient.Options {
 	if opt.TokenExpiration == 0 {
 		opt.TokenExpiration = DefaultTokenExpiration
 	}
+	provider := opt.CredentialProvider
+	if provider == nil {
+		provider = &staticCredentialProvider{credentials: &Credentials{Token: opt.AuthToken, User: opt.AuthUser}}
+	}
 	tokenCache := cache.New(opt.TokenExpiration, 1*time.Hour)
 	options.SignRequest = func(req *http.Request) error {
 		if req.URL.Path == TokenPath {
 			return nil
 		}
-		if opt.AuthToken != "" {
-			req.Header.Set(HeaderAuth, "Bearer "+opt.AuthToken)
+		credentials, err := provider.Credentials()
+		if err != nil {
+			return err
+		}
+		if credentials == nil {
+			return ErrNoCredentials
+		}
+		if credentials.Token != "" {
+			req.Header.Set(HeaderAuth, "Bearer "+credentials.Token)
 			return nil
 		}
-		cachedToken, isFound := tokenCache.Get("token")
+		if credentials.User == nil {
+			return ErrNoCredentials
+		}
+		// the token is cached by user, so that a rotated password generates a new token
+		key := credentials.cacheKey()
+		cachedToken, isFound := tokenCache.Get(key)
 		if isFound {
 			req.Header.Set(HeaderAuth, "Bearer "+cachedToken.(string))
 		} else {
-			token, err := c.GetToken(opt.AuthUser)
+			token, err := c.GetToken(credentials.User)
 			if err != nil {
 				return err
 			}
 			req.Header.Set(HeaderAuth, "Bearer "+token)
-			tokenCache.Set("token", token, cache.DefaultExpiration)
+			tokenCache.Set(key, token, cache.DefaultExpiration)
 		}
 		return nil
 	}
@@ -224,7 +370,7 @@ func (c *Client) updateAPIPath() {
 }
 
 func (c *Client) CheckReadiness() int {
-	return c.pool.CheckReadiness()
+	return c.addressPool().CheckReadiness()
 }
 
 // SyncEndpoints gets the endpoints of service-center in the cluster
@@ -237,7 +383,124 @@ func (c *Client) SyncEndpoints() error {
 	if err != nil {
 		return fmt.Errorf("sync SC ep failed. err:%s", err.Error())
 	}
-	return c.pool.SetAddressByInstances(instances)
+	return c.addressPool().SetAddressByInstances(instances)
+}
+
+// autoSyncEndpoints runs syncClusterEndpoints periodically until the client is closed
+func (c *Client) autoSyncEndpoints() {
+	interval := c.options().SyncEndpointsInterval
+	if interval <= 0 {
+		interval = DefaultSyncEndpointsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.syncClusterEndpoints(); err != nil {
+			openlog.Warn(err.Error())
+		}
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncClusterEndpoints merges the healthy SC cluster members with the seed endpoints.
+// If the health query fails, the members discovered last time are asked one by one,
+// and the address pool falls back to the seed endpoints only if all of them fail or no member is healthy.
+func (c *Client) syncClusterEndpoints() error {
+	instances, err := c.Health()
+	if err != nil {
+		c.poolMutex.Lock()
+		members := c.discoveredEndpoints
+		c.poolMutex.Unlock()
+		for _, member := range members {
+			if instances, err = c.health(&CallOptions{Address: member}); err == nil {
+				break
+			}
+		}
+	}
+	c.poolMutex.Lock()
+	defer c.poolMutex.Unlock()
+	seeds := c.options().Endpoints
+	if c.activeEndpoints != nil {
+		// switched to the peer engine
+		seeds = c.activeEndpoints
+	}
+	if err != nil {
+		c.discoveredEndpoints = nil
+		c.resetSyncedEndpoints(seeds)
+		return fmt.Errorf("sync SC ep failed. err:%s", err.Error())
+	}
+	discovered := c.clusterEndpoints(instances)
+	c.discoveredEndpoints = discovered
+	if len(discovered) == 0 {
+		openlog.Warn("no healthy SC cluster member is discovered, fall back to the seed endpoints")
+		c.resetSyncedEndpoints(seeds)
+		return nil
+	}
+	c.resetSyncedEndpoints(mergeEndpoints(seeds, discovered))
+	return nil
+}
+
+// resetSyncedEndpoints resets the pool only when the address list is changed,
+// so that the availability of addresses is not lost on every sync
+func (c *Client) resetSyncedEndpoints(endpoints []string) {
+	if equalEndpoints(c.syncedEndpoints, endpoints) {
+		return
+	}
+	c.syncedEndpoints = endpoints
+	c.addressPool().ResetAddress(endpoints)
+	openlog.Info(fmt.Sprintf("SC endpoints are synced to %v", endpoints))
+}
+
+// clusterEndpoints returns the addresses of UP instances whose endpoints match the protocol of the client
+func (c *Client) clusterEndpoints(instances []*discovery.MicroServiceInstance) []string {
+	var endpoints []string
+	for _, instance := range instances {
+		if instance == nil || instance.Status != MSInstanceUP {
+			continue
+		}
+		for _, ep := range instance.Endpoints {
+			u, err := url.Parse(ep)
+			if err != nil || u.Host == "" {
+				openlog.Warn(fmt.Sprintf("ignore invalid SC endpoint %s", ep))
+				continue
+			}
+			sslEnabled := u.Query().Get("sslEnabled") == "true"
+			if sslEnabled != c.options().EnableSSL {
+				continue
+			}
+			endpoints = mergeEndpoints(endpoints, []string{u.Host})
+		}
+	}
+	return endpoints
+}
+
+func mergeEndpoints(a, b []string) []string {
+	merged := make([]string, 0, len(a)+len(b))
+	seen := make(map[string]bool, len(a)+len(b))
+	for _, ep := range append(append([]string{}, a...), b...) {
+		if ep == "" || seen[ep] {
+			continue
+		}
+		seen[ep] = true
+		merged = append(merged, ep)
+	}
+	return merged
+}
+
+func equalEndpoints(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func (c *Client) formatURL(api string, querys []URLParameter, options *CallOptions) string {
@@ -245,8 +508,11 @@ func (c *Client) formatURL(api string, querys []URLParameter, options *CallOptio
 	if options != nil && len(options.Address) != 0 {
 		host = options.Address
 	}
+	c.cfgMutex.RLock()
+	protocol := c.protocol
+	c.cfgMutex.RUnlock()
 	builder := URLBuilder{
-		Protocol:      c.protocol,
+		Protocol:      protocol,
 		Host:          host,
 		Path:          api,
 		URLParameters: querys,
@@ -268,13 +534,40 @@ func (c *Client) GetDefaultHeaders() http.Header {
 
 // httpDo makes the http request to Service-center with proper header, body and method
 func (c *Client) httpDo(method string, rawURL string, headers http.Header, body []byte) (resp *http.Response, err error) {
+	return c.httpDoContext(context.Background(), method, rawURL, headers, body)
+}
+
+func (c *Client) httpDoContext(ctx context.Context, method string, rawURL string, headers http.Header,
+	body []byte) (resp *http.Response, err error) {
+	c.reloadRoots()
 	if len(headers) == 0 {
 		headers = make(http.Header)
 	}
 	for k, v := range c.GetDefaultHeaders() {
 		headers[k] = v
 	}
-	return c.client.Do(context.Background(), method, rawURL, headers, body)
+	retries := c.options().MaxThrottledRetries
+	if retries == 0 {
+		retries = DefaultMaxThrottledRetries
+	}
+	for i := 0; ; i++ {
+		release, err := c.throttle(ctx, method, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.httpClient().Do(ctx, method, rawURL, headers, body)
+		release()
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || i >= retries {
+			return resp, err
+		}
+		// service-center is overloaded, retry after the time it asks for
+		wait := retryAfter(resp)
+		resp.Body.Close()
+		openlog.Warn(fmt.Sprintf("%s %s is throttled by service center, retry after %v", method, rawURL, wait))
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
 }
 
 // RegisterService registers the micro-services to Service-Center
@@ -313,12 +606,84 @@ func (c *Client) RegisterService(microService *discovery.MicroService) (string,
 		return response.ServiceId, nil
 	}
 	if resp.StatusCode == 400 {
+		if strings.Contains(string(body), "\"errorCode\":\"400010\"") {
+			return "", ErrMicroServiceExists
+		}
 		return "", fmt.Errorf("client seems to have erred, error: %s", body)
 	}
 	return "", fmt.Errorf("register service failed, ServiceName/responseStatusCode/responsebody: %s/%d/%s",
 		microService.ServiceName, resp.StatusCode, string(body))
 }
 
+// RegisterOrGetService registers the micro-service, if it is already registered with the same app, name,
+// version and environment, the existing service id is returned instead. created is true only if the service is
+// registered by this call. If the existing service declares a different schema list, the service id is returned
+// with ErrSchemaListMismatch, because service-center rejects the schemas which are not in the registered list.
+func (c *Client) RegisterOrGetService(microService *discovery.MicroService, opts ...RegisterOption) (id string, created bool, err error) {
+	if microService == nil {
+		return "", false, ErrNil
+	}
+	ropts := &RegisterOptions{}
+	for _, opt := range opts {
+		opt(ropts)
+	}
+	id, err = c.getMicroServiceID(microService.AppId, microService.ServiceName, microService.Version,
+		microService.Environment, &CallOptions{})
+	if err != nil {
+		return "", false, err
+	}
+	if id == "" {
+		id, err = c.RegisterService(microService)
+		if err == nil {
+			return id, true, nil
+		}
+		if err != ErrMicroServiceExists {
+			return "", false, err
+		}
+		// registered by others at the same time
+		id, err = c.getMicroServiceID(microService.AppId, microService.ServiceName, microService.Version,
+			microService.Environment, &CallOptions{})
+		if err != nil {
+			return "", false, err
+		}
+		if id == "" {
+			return "", false, ErrMicroServiceExists
+		}
+	}
+	microService.ServiceId = id
+	existing, err := c.GetMicroService(id)
+	if err != nil {
+		return id, false, err
+	}
+	if !sameSchemaList(existing.Schemas, microService.Schemas) {
+		return id, false, fmt.Errorf("%w, registered: %v, declared: %v", ErrSchemaListMismatch, existing.Schemas, microService.Schemas)
+	}
+	if ropts.UpdateProperties && microService.Properties != nil {
+		if _, err = c.UpdateMicroServiceProperties(id, microService); err != nil {
+			return id, false, err
+		}
+	}
+	return id, false, nil
+}
+
+// sameSchemaList compares the schema ids ignoring the order
+func sameSchemaList(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int, len(a))
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		if count[s] == 0 {
+			return false
+		}
+		count[s]--
+	}
+	return true
+}
+
 // GetProviders gets a list of provider for a particular consumer
 func (c *Client) GetProviders(consumer string, opts ...CallOption) (*MicroServiceProvideResponse, error) {
 	copts := &CallOptions{}
@@ -326,7 +691,7 @@ func (c *Client) GetProviders(consumer string, opts ...CallOption) (*MicroServic
 		opt(copts)
 	}
 	providersURL := c.formatURL(fmt.Sprintf("%s%s/%s/providers", MSAPIPath, MicroservicePath, consumer), nil, copts)
-	resp, err := c.httpDo("GET", providersURL, nil, nil)
+	resp, err := c.readDo("GET", providersURL, nil, nil, copts)
 	if err != nil {
 		return nil, fmt.Errorf("get Providers failed, error: %s, MicroServiceid: %s", err, consumer)
 	}
@@ -390,7 +755,7 @@ func (c *Client) AddSchemas(microServiceID, schemaName, schemaInfo string) error
 	return nil
 }
 
-// GetSchema gets Schema list for the microservice from service-center
+// GetSchema gets the schema of the microservice from service-center, ErrSchemaNotExists if it does not exist
 func (c *Client) GetSchema(microServiceID, schemaName string, opts ...CallOption) ([]byte, error) {
 	if microServiceID == "" {
 		return []byte(""), errors.New("invalid micro service ID")
@@ -400,7 +765,7 @@ func (c *Client) GetSchema(microServiceID, schemaName string, opts ...CallOption
 		opt(copts)
 	}
 	url := c.formatURL(fmt.Sprintf("%s%s/%s/%s/%s", MSAPIPath, MicroservicePath, microServiceID, "schemas", schemaName), nil, copts)
-	resp, err := c.httpDo("GET", url, nil, nil)
+	resp, err := c.readDo("GET", url, nil, nil, copts)
 	if err != nil {
 		return []byte(""), err
 	}
@@ -415,16 +780,94 @@ func (c *Client) GetSchema(microServiceID, schemaName string, opts ...CallOption
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		return body, nil
 	}
+	if resp.StatusCode == http.StatusNotFound ||
+		(resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "\"errorCode\":\"400016\"")) {
+		return []byte(""), ErrSchemaNotExists
+	}
+	return []byte(""), fmt.Errorf("GetSchema failed, MicroServiceId/SchemaId: %s/%s, response StatusCode: %d, response body: %s",
+		microServiceID, schemaName, resp.StatusCode, string(body))
+}
 
-	return []byte(""), err
+// ListSchemas returns the schema ids and summaries of the service, the content is returned with WithSchemaContent
+func (c *Client) ListSchemas(microServiceID string, opts ...CallOption) ([]*discovery.Schema, error) {
+	if microServiceID == "" {
+		return nil, errors.New("invalid micro service ID")
+	}
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	var params []URLParameter
+	if copts.WithSchemaContent {
+		params = append(params, URLParameter{"withSchema": "1"})
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s", MSAPIPath, MicroservicePath, microServiceID, SchemaPath), params, copts)
+	resp, err := c.readDo("GET", url, nil, nil, copts)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("ListSchemas failed, response is empty, MicroServiceId: %s", microServiceID)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewIOException(err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var response discovery.GetAllSchemaResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, NewJSONException(err, string(body))
+		}
+		return response.Schemas, nil
+	}
+	if resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "\"errorCode\":\"400012\"") {
+		return nil, ErrMicroServiceNotExists
+	}
+	return nil, fmt.Errorf("ListSchemas failed, MicroServiceId: %s, response StatusCode: %d, response body: %s",
+		microServiceID, resp.StatusCode, string(body))
 }
 
-// GetMicroServiceID gets the microserviceid by appID, serviceName and version
+// DeleteSchema deletes the schema of the service, ErrSchemaNotExists if it does not exist
+func (c *Client) DeleteSchema(microServiceID, schemaID string) error {
+	if microServiceID == "" || schemaID == "" {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s/%s", MSAPIPath, MicroservicePath, microServiceID, SchemaPath, schemaID), nil, nil)
+	resp, err := c.httpDo("DELETE", url, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("DeleteSchema failed, response is empty, MicroServiceId/SchemaId: %s/%s", microServiceID, schemaID)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return NewIOException(err)
+	}
+	if resp.StatusCode == http.StatusNotFound ||
+		(resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "\"errorCode\":\"400016\"")) {
+		return ErrSchemaNotExists
+	}
+	return NewCommonException("delete schema failed, MicroServiceId/SchemaId: %s/%s, response StatusCode: %d, response body: %s",
+		microServiceID, schemaID, resp.StatusCode, string(body))
+}
+
+// GetMicroServiceID gets the microserviceid by appID, serviceName and version,
+// if env is empty, the environment of WithEnvironment or Options.Environment is used
 func (c *Client) GetMicroServiceID(appID, microServiceName, version, env string, opts ...CallOption) (string, error) {
 	copts := &CallOptions{}
 	for _, opt := range opts {
 		opt(copts)
 	}
+	return c.getMicroServiceID(appID, microServiceName, version, c.environment(env, copts), copts)
+}
+
+// getMicroServiceID checks the existence of the service in exactly the environment
+func (c *Client) getMicroServiceID(appID, microServiceName, version, env string, copts *CallOptions) (string, error) {
 	url := c.formatURL(MSAPIPath+ExistencePath, []URLParameter{
 		{"type": "microservice"},
 		{"appId": appID},
@@ -432,7 +875,7 @@ func (c *Client) GetMicroServiceID(appID, microServiceName, version, env string,
 		{"version": version},
 		{"env": env},
 	}, copts)
-	resp, err := c.httpDo("GET", url, nil, nil)
+	resp, err := c.readDo("GET", url, nil, nil, copts)
 	if err != nil {
 		return "", err
 	}
@@ -463,7 +906,7 @@ func (c *Client) GetAllMicroServices(opts ...CallOption) ([]*discovery.MicroServ
 		opt(copts)
 	}
 	url := c.formatURL(MSAPIPath+MicroservicePath, nil, copts)
-	resp, err := c.httpDo("GET", url, nil, nil)
+	resp, err := c.readDo("GET", url, nil, nil, copts)
 	if err != nil {
 		return nil, err
 	}
@@ -523,7 +966,7 @@ func (c *Client) GetMicroService(microServiceID string, opts ...CallOption) (*di
 		opt(copts)
 	}
 	microserviceURL := c.formatURL(fmt.Sprintf("%s%s/%s", MSAPIPath, MicroservicePath, microServiceID), nil, copts)
-	resp, err := c.httpDo("GET", microserviceURL, nil, nil)
+	resp, err := c.readDo("GET", microserviceURL, nil, nil, copts)
 	if err != nil {
 		return nil, err
 	}
@@ -546,8 +989,21 @@ func (c *Client) GetMicroService(microServiceID string, opts ...CallOption) (*di
 	return nil, fmt.Errorf("GetMicroService failed, MicroServiceId: %s, response StatusCode: %d, response body: %s\n, microserviceURL: %s", microServiceID, resp.StatusCode, string(body), microserviceURL)
 }
 
+// environment returns env if it is given or the call is WithExactEnvironment,
+// otherwise the environment of the call or the client
+func (c *Client) environment(env string, copts *CallOptions) string {
+	if env != "" || copts.ExactEnvironment {
+		return env
+	}
+	if copts.Environment != "" {
+		return copts.Environment
+	}
+	return c.options().Environment
+}
+
 // BatchFindInstances fetch instances based on service name, env, app and version
-// finally it return instances grouped by service name
+// finally it return instances grouped by service name.
+// The keys without environment use the environment of WithEnvironment or Options.Environment.
 func (c *Client) BatchFindInstances(consumerID string, keys []*discovery.FindService, opts ...CallOption) (*discovery.BatchFindInstancesResponse, error) {
 	copts := &CallOptions{}
 	for _, opt := range opts {
@@ -556,6 +1012,18 @@ func (c *Client) BatchFindInstances(consumerID string, keys []*discovery.FindSer
 	if len(keys) == 0 {
 		return nil, ErrEmptyCriteria
 	}
+	if env := c.environment("", copts); env != "" {
+		scoped := make([]*discovery.FindService, 0, len(keys))
+		for _, key := range keys {
+			if key != nil && key.Service != nil && key.Service.Environment == "" {
+				service := *key.Service
+				service.Environment = env
+				key = &discovery.FindService{Service: &service, Rev: key.Rev}
+			}
+			scoped = append(scoped, key)
+		}
+		keys = scoped
+	}
 	url := c.formatURL(MSAPIPath+BatchInstancePath, []URLParameter{
 		{"type": "query"},
 	}, copts)
@@ -567,7 +1035,7 @@ func (c *Client) BatchFindInstances(consumerID string, keys []*discovery.FindSer
 	if err != nil {
 		return nil, NewJSONException(err, string(rBody))
 	}
-	resp, err := c.httpDo("POST", url, http.Header{"X-ConsumerId": []string{consumerID}}, rBody)
+	resp, err := c.readDo("POST", url, http.Header{"X-ConsumerId": []string{consumerID}}, rBody, copts)
 	if err != nil {
 		return nil, err
 	}
@@ -581,7 +1049,17 @@ func (c *Client) BatchFindInstances(consumerID string, keys []*discovery.FindSer
 		if err != nil {
 			return nil, NewJSONException(err, string(body))
 		}
-
+		if response != nil && response.Instances != nil {
+			for _, updated := range response.Instances.Updated {
+				if updated == nil {
+					continue
+				}
+				var filtered bool
+				if updated.Instances, filtered = c.filterOutliers(updated.Instances); filtered {
+					updated.Rev = ""
+				}
+			}
+		}
 		return response, nil
 	}
 	return nil, fmt.Errorf("batch find failed, status %d, body %s", resp.StatusCode, body)
@@ -592,6 +1070,11 @@ func (c *Client) BatchFindInstances(consumerID string, keys []*discovery.FindSer
 // Deprecated: use FindInstances instead
 func (c *Client) FindMicroServiceInstances(consumerID, appID, microServiceName,
 	versionRule string, opts ...CallOption) ([]*discovery.MicroServiceInstance, error) {
+	// the rule used to be escaped by the callers, like 0%2B, it is escaped when the url is built.
+	// PathUnescape keeps the + of the unescaped rules like 1.0.0+, QueryUnescape would turn it into a space
+	if unescaped, err := url.PathUnescape(versionRule); err == nil {
+		versionRule = unescaped
+	}
 	rst, err := c.findInstances(consumerID, appID, microServiceName, versionRule, opts...)
 	if err != nil {
 		return nil, err
@@ -599,26 +1082,39 @@ func (c *Client) FindMicroServiceInstances(consumerID, appID, microServiceName,
 	return rst.Instances, nil
 }
 
-// FindInstances find microservice instance
+// FindInstances find microservice instance, all the versions are found unless WithVersionRule is given,
+// the providers are in the environment of WithEnvironment or Options.Environment if any
 func (c *Client) FindInstances(consumerID, appID, microServiceName string,
 	opts ...CallOption) (*FindMicroServiceInstancesResult, error) {
-	return c.findInstances(consumerID, appID, microServiceName, "0%2B", opts...) // 0+, all version
+	return c.findInstances(consumerID, appID, microServiceName, "", opts...)
 }
 
-// FindInstances find microservice instance using consumerID, appID, name
+// findInstances find microservice instance using consumerID, appID, name,
+// the rule of WithVersionRule is used if versionRule is empty
 func (c *Client) findInstances(consumerID, appID, microServiceName,
 	versionRule string, opts ...CallOption) (*FindMicroServiceInstancesResult, error) {
 	copts := &CallOptions{}
 	for _, opt := range opts {
 		opt(copts)
 	}
+	if versionRule == "" {
+		rule := AllVersions
+		if copts.VersionRule != nil {
+			rule = *copts.VersionRule
+		}
+		if rule.IsZero() {
+			return nil, fmt.Errorf("%w: the rule is empty", ErrInvalidVersionRule)
+		}
+		versionRule = rule.String()
+	}
 	microserviceInstanceURL := c.formatURL(MSAPIPath+InstancePath, []URLParameter{
 		{"appId": appID},
 		{"serviceName": microServiceName},
 		{"version": versionRule},
+		{"env": c.environment("", copts)},
 	}, copts)
 
-	resp, err := c.httpDo("GET", microserviceInstanceURL, http.Header{"X-ConsumerId": []string{consumerID}}, nil)
+	resp, err := c.readDo("GET", microserviceInstanceURL, http.Header{"X-ConsumerId": []string{consumerID}}, nil, copts)
 	if err != nil {
 		return nil, err
 	}
@@ -636,10 +1132,12 @@ func (c *Client) findInstances(consumerID, appID, microServiceName,
 		if err != nil {
 			return nil, NewJSONException(err, string(body))
 		}
-		return &FindMicroServiceInstancesResult{
-			Instances: response.Instances,
-			Revision:  resp.Header.Get(HeaderRevision),
-		}, nil
+		result := &FindMicroServiceInstancesResult{Revision: resp.Header.Get(HeaderRevision)}
+		var filtered bool
+		if result.Instances, filtered = c.filterOutliers(response.Instances); filtered {
+			result.Revision = ""
+		}
+		return result, nil
 	}
 	if resp.StatusCode == http.StatusNotModified {
 		return nil, ErrNotModified
@@ -695,10 +1193,20 @@ func (c *Client) GetMicroServiceInstances(consumerID, providerID string, opts ..
 	for _, opt := range opts {
 		opt(copts)
 	}
+	instances, err := c.getMicroServiceInstances(consumerID, providerID, copts)
+	if err != nil {
+		return nil, err
+	}
+	instances, _ = c.filterOutliers(instances)
+	return instances, nil
+}
+
+// getMicroServiceInstances returns all the instances of provider, including the outliers
+func (c *Client) getMicroServiceInstances(consumerID, providerID string, copts *CallOptions) ([]*discovery.MicroServiceInstance, error) {
 	url := c.formatURL(fmt.Sprintf("%s%s/%s%s", MSAPIPath, MicroservicePath, providerID, InstancePath), nil, copts)
-	resp, err := c.httpDo("GET", url, http.Header{
+	resp, err := c.readDo("GET", url, http.Header{
 		"X-ConsumerId": []string{consumerID},
-	}, nil)
+	}, nil, copts)
 	if err != nil {
 		return nil, err
 	}
@@ -728,35 +1236,23 @@ func (c *Client) GetAllResources(resource string, opts ...CallOption) ([]*discov
 	for _, opt := range opts {
 		opt(copts)
 	}
-	url := c.formatURL(GovernAPIPATH+MicroservicePath, []URLParameter{
+	response, err := c.getServicesInfo("GetAllResources", []URLParameter{
 		{"options": resource},
 	}, copts)
-	resp, err := c.httpDo("GET", url, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	if resp == nil {
-		return nil, errors.New("GetAllResources failed, response is empty")
-	}
-	var body []byte
-	body, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, NewIOException(err)
-	}
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		var response discovery.GetServicesInfoResponse
-		err = json.Unmarshal(body, &response)
-		if err != nil {
-			return nil, NewJSONException(err, string(body))
-		}
-		return response.AllServicesDetail, nil
-	}
-	return nil, fmt.Errorf("GetAllResources failed, response StatusCode: %d, response body: %s", resp.StatusCode, string(body))
+	return response.AllServicesDetail, nil
 }
 
 // Health returns the list of all the endpoints of SC with their status
 func (c *Client) Health() ([]*discovery.MicroServiceInstance, error) {
-	url := c.formatURL(MSAPIPath+"/health", nil, nil)
+	return c.health(nil)
+}
+
+// health queries the cluster members, from the address of copts if it is given
+func (c *Client) health(copts *CallOptions) ([]*discovery.MicroServiceInstance, error) {
+	url := c.formatURL(MSAPIPath+"/health", nil, copts)
 	resp, err := c.httpDo("GET", url, nil, nil)
 	if err != nil {
 		return nil, err
@@ -797,72 +1293,111 @@ func (c *Client) Heartbeat(microServiceID, microServiceInstanceID string) (bool,
 		if err != nil {
 			return false, NewIOException(err)
 		}
+		if resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "\"errorCode\":\"400017\"") {
+			return false, ErrMicroServiceInstanceNotExists
+		}
 		return false, NewCommonException("result: %d %s", resp.StatusCode, string(body))
 	}
 	return true, nil
 }
 
-// WSHeartbeat creates a web socket connection to service-center to send heartbeat.
-// It relies on the ping pong mechanism of websocket to ensure the heartbeat, which is maintained by goroutines.
-// After the connection is established, the communication fails and will be retried continuously. The retrial time increases exponentially.
-// The callback function is used to re-register the instance.
-func (c *Client) WSHeartbeat(microServiceID, microServiceInstanceID string, callback func()) error {
-	err := c.setupWSConnection(microServiceID, microServiceInstanceID)
+// BatchHeartbeat sends the heartbeats of a batch of instances to service-center,
+// the result of each instance is returned, an instance with ErrMessage failed to renew its lease
+func (c *Client) BatchHeartbeat(instances []*discovery.HeartbeatSetElement) ([]*discovery.InstanceHbRst, error) {
+	if len(instances) == 0 {
+		return nil, errors.New("batch heartbeat instances is empty")
+	}
+	url := c.formatURL(MSAPIPath+BatchHeartbeatPath, nil, nil)
+	request := &discovery.HeartbeatSetRequest{
+		Instances: instances,
+	}
+	body, err := json.Marshal(request)
 	if err != nil {
-		return err
+		return nil, NewJSONException(err, string(body))
+	}
+	resp, err := c.httpDo("PUT", url, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("batch heartbeat failed, response is empty")
+	}
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewIOException(err)
 	}
-	go func() {
-		resetConn := func() error {
-			return c.setupWSConnection(microServiceID, microServiceInstanceID)
+	var response discovery.HeartbeatSetResponse
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, NewJSONException(err, string(body))
 		}
-		for {
-			conn := c.conns[microServiceInstanceID]
-			_, _, err = conn.ReadMessage()
-			if err != nil {
-				openlog.Error(err.Error())
-				closeErr := conn.Close()
-				if closeErr != nil {
-					openlog.Error(fmt.Sprintf("failed to close websocket connection %s", closeErr.Error()))
-				}
-				if websocket.IsCloseError(err, discovery.ErrWebsocketInstanceNotExists) {
-					// If the instance does not exist, it is closed normally and should be re-registered
-					callback()
-				}
-				// reconnection
-				err = backoff.RetryNotify(
-					resetConn,
-					backoff.NewExponentialBackOff(),
-					func(err error, duration time.Duration) {
-						openlog.Error(fmt.Sprintf("failed err: %s,and it will be executed again in %v", err.Error(), duration))
-					})
-			}
+		return response.Instances, nil
+	case http.StatusBadRequest:
+		// service-center responds 400 with the result of each instance if some of them failed
+		if json.Unmarshal(body, &response) == nil && len(response.Instances) > 0 {
+			return response.Instances, nil
 		}
-	}()
-	return nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrBatchHeartbeatNotSupported
+	}
+	return nil, NewCommonException("result: %d %s", resp.StatusCode, string(body))
 }
 
-// setupWSConnection create websocket connection and assign it to the map of the connection
-func (c *Client) setupWSConnection(microServiceID, microServiceInstanceID string) error {
-	scheme := "wss"
-	if !c.opt.EnableSSL {
-		scheme = "ws"
+// WSHeartbeat creates a web socket connection to service-center to send heartbeat.
+// It relies on the ping pong mechanism of websocket to ensure the heartbeat, which is maintained by goroutines,
+// the client also sends ping messages every Options.HeartbeatInterval.
+// After the connection is established, the communication fails and will be retried continuously. The retrial time increases exponentially.
+// If service-center does not support websocket, which is responded with 404, 405, 426 or 501 to the upgrade,
+// the heartbeat falls back to Heartbeat over http, the other handshake failures are retried.
+// The callback function is used to re-register the instance.
+// The returned handle is used to stop the heartbeat, which is also stopped when the client is closed.
+func (c *Client) WSHeartbeat(microServiceID, microServiceInstanceID string, callback func()) (*HeartbeatHandle, error) {
+	conn, err := c.setupWSConnection(microServiceID, microServiceInstanceID)
+	if err != nil && err != errWebsocketUpgradeRefused {
+		return nil, err
 	}
+	h := newHeartbeatHandle(c, microServiceID, microServiceInstanceID, callback)
+	go h.run(conn)
+	return h, nil
+}
 
+// setupWSConnection create websocket connection and assign it to the map of the connection
+func (c *Client) setupWSConnection(microServiceID, microServiceInstanceID string) (*websocket.Conn, error) {
 	u := url.URL{
-		Scheme: scheme,
+		Scheme: c.wsScheme(),
 		Host:   c.GetAddress(),
 		Path: fmt.Sprintf("%s%s/%s%s/%s%s", MSAPIPath, MicroservicePath, microServiceID,
-			InstancePath, microServiceInstanceID, "/heartbeat"),
+			InstancePath, microServiceInstanceID, HeartbeatPath),
 	}
 
-	conn, _, err := c.dialWebsocket(&u)
+	conn, resp, err := c.dialWebsocket(&u)
 	if err != nil {
-		openlog.Error(fmt.Sprintf("watching microservice dial catch an exception,microServiceID: %s, error:%s", microServiceID, err.Error()))
-		return err
+		openlog.Error(fmt.Sprintf("heartbeat dial catch an exception,microServiceID: %s, error:%s", microServiceID, err.Error()))
+		if err == websocket.ErrBadHandshake && resp != nil {
+			if websocketUnsupported(resp.StatusCode) {
+				return nil, errWebsocketUpgradeRefused
+			}
+			// the errors like 401 and 503 are retried
+			return nil, fmt.Errorf("websocket handshake failed, response StatusCode: %d", resp.StatusCode)
+		}
+		return nil, err
 	}
+	c.mutex.Lock()
 	c.conns[microServiceInstanceID] = conn
+	c.mutex.Unlock()
 	openlog.Info(fmt.Sprintf("%s's websocket connection established successfully", microServiceInstanceID))
-	return nil
+	return conn, nil
+}
+
+// websocketUnsupported returns whether the status of the handshake means service-center does not support websocket
+func websocketUnsupported(status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusUpgradeRequired, http.StatusNotImplemented:
+		return true
+	}
+	return false
 }
 
 // UnregisterMicroServiceInstance un-registers the microservice instance from the service-center
@@ -1000,6 +1535,9 @@ func (c *Client) UpdateMicroServiceProperties(microServiceID string, microServic
 
 // Close closes the connection with Service-Center
 func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stopCh)
+	})
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	for k, v := range c.conns {
@@ -1009,7 +1547,7 @@ func (c *Client) Close() error {
 		}
 		delete(c.conns, k)
 	}
-	c.pool.Close()
+	c.addressPool().Close()
 	return nil
 }
 
@@ -1020,13 +1558,9 @@ func (c *Client) WatchMicroServiceWithExtraHandle(microServiceID string, callbac
 	if ready, ok := c.watchers[microServiceID]; !ok || !ready {
 		openlog.Info(fmt.Sprintf("WatchMicroServiceWithExtraHandle watch, microServiceID:%s", microServiceID))
 		c.watchers[microServiceID] = true
-		scheme := "wss"
-		if !c.opt.EnableSSL {
-			scheme = "ws"
-		}
 		host := c.GetAddress()
 		u := url.URL{
-			Scheme: scheme,
+			Scheme: c.wsScheme(),
 			Host:   host,
 			Path: fmt.Sprintf("%s%s/%s%s", MSAPIPath,
 				MicroservicePath, microServiceID, WatchPath),
@@ -1122,12 +1656,8 @@ func (c *Client) WatchMicroService(microServiceID string, callback func(*MicroSe
 		c.mutex.Lock()
 		if ready, ok := c.watchers[microServiceID]; !ok || !ready {
 			c.watchers[microServiceID] = true
-			scheme := "wss"
-			if !c.opt.EnableSSL {
-				scheme = "ws"
-			}
 			u := url.URL{
-				Scheme: scheme,
+				Scheme: c.wsScheme(),
 				Host:   c.GetAddress(),
 				Path: fmt.Sprintf("%s%s/%s%s", MSAPIPath,
 					MicroservicePath, microServiceID, WatchPath),
@@ -1171,7 +1701,7 @@ func (c *Client) WatchMicroService(microServiceID string, callback func(*MicroSe
 }
 
 func (c *Client) GetAddress() string {
-	return c.pool.GetAvailableAddress()
+	return c.addressPool().GetAvailableAddress()
 }
 
 func (c *Client) startBackOff(microServiceID string, callback func(*MicroServiceInstanceChangedEvent)) {
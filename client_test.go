@@ -2,16 +2,19 @@ package sc_test
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-chassis/cari/discovery"
 	"github.com/go-chassis/cari/rbac"
 	"github.com/go-chassis/openlog"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/go-chassis/sc-client"
@@ -69,6 +72,37 @@ func TestClient_RegisterService(t *testing.T) {
 		t.Log(err)
 		assert.Error(t, err)
 	})
+	t.Run("register or get service twice, should return the same id", func(t *testing.T) {
+		ms := &discovery.MicroService{
+			ServiceName: "idempotentService",
+			Version:     "0.0.1",
+			Schemas:     []string{"s1", "s2"},
+		}
+		sid, created, err := c.RegisterOrGetService(ms)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		defer c.UnregisterMicroService(sid)
+
+		sid2, created, err := c.RegisterOrGetService(&discovery.MicroService{
+			ServiceName: "idempotentService",
+			Version:     "0.0.1",
+			Schemas:     []string{"s2", "s1"},
+			Properties:  map[string]string{"owner": "a"},
+		}, sc.WithPropertiesUpdate())
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, sid, sid2)
+		s, err := c.GetMicroService(sid)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", s.Properties["owner"])
+
+		_, _, err = c.RegisterOrGetService(&discovery.MicroService{
+			ServiceName: "idempotentService",
+			Version:     "0.0.1",
+			Schemas:     []string{"s3"},
+		})
+		assert.True(t, errors.Is(err, sc.ErrSchemaListMismatch))
+	})
 	t.Run("get all apps, not empty", func(t *testing.T) {
 		apps, err := c.GetAllApplications()
 		assert.NoError(t, err)
@@ -147,8 +181,12 @@ func TestRegistryClient_FindMicroServiceInstances(t *testing.T) {
 		callback := func() {
 			registryClient.RegisterMicroServiceInstance(microServiceInstance)
 		}
-		err = registryClient.WSHeartbeat(microServiceInstance.ServiceId, iid, callback)
+		h, err := registryClient.WSHeartbeat(microServiceInstance.ServiceId, iid, callback)
 		assert.Nil(t, err)
+		assert.Equal(t, sc.HeartbeatConnected, h.State())
+		h.Stop()
+		<-h.Done()
+		assert.Equal(t, sc.HeartbeatStopped, h.State())
 		ok, err := registryClient.UnregisterMicroServiceInstance(microServiceInstance.ServiceId, iid)
 		assert.NoError(t, err)
 		assert.True(t, ok)
@@ -396,3 +434,183 @@ func TestClient_SyncEndpoints(t *testing.T) {
 	// sc stopped, should use the synced address
 	assert.Equal(t, anotherScServer.Listener.Addr().String(), c.GetAddress())
 }
+
+func TestClient_AutoSyncEndpoints(t *testing.T) {
+	os.Setenv("CHASSIS_SC_HEALTH_CHECK_INTERVAL", "1")
+
+	var members []string
+	health := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		resp := &discovery.GetInstancesResponse{}
+		for _, ep := range members {
+			resp.Instances = append(resp.Instances, &discovery.MicroServiceInstance{
+				Endpoints: []string{ep},
+				HostName:  "test",
+				Status:    sc.MSInstanceUP,
+			})
+		}
+		instanceBytes, err := json.Marshal(resp)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writer.Write(instanceBytes)
+	})
+	anotherScServer := httptest.NewServer(health)
+	defer anotherScServer.Close()
+	scServer := httptest.NewServer(health)
+	members = []string{
+		"rest://127.0.0.1:1?sslEnabled=true", // protocol mismatched, should be ignored
+		"rest://" + anotherScServer.Listener.Addr().String(),
+	}
+
+	c, err := sc.NewClient(
+		sc.Options{
+			Endpoints:             []string{scServer.Listener.Addr().String()},
+			AutoSyncEndpoints:     true,
+			SyncEndpointsInterval: 500 * time.Millisecond,
+		})
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, scServer.Listener.Addr().String(), c.GetAddress()) // seed first
+
+	scServer.Close()
+	// seed stopped, should use the discovered address
+	assert.Eventually(t, func() bool {
+		return c.GetAddress() == anotherScServer.Listener.Addr().String()
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestClient_AutoSyncEndpointsFallback(t *testing.T) {
+	var seedFailing, memberFailing int32
+	var seedHits, memberHits int32
+	var member *httptest.Server
+	newHealth := func(failing, hits *int32) http.HandlerFunc {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			atomic.AddInt32(hits, 1)
+			if atomic.LoadInt32(failing) == 1 {
+				writer.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			b, _ := json.Marshal(&discovery.GetInstancesResponse{Instances: []*discovery.MicroServiceInstance{{
+				Endpoints: []string{"rest://" + member.Listener.Addr().String()},
+				Status:    sc.MSInstanceUP,
+			}}})
+			writer.Write(b)
+		}
+	}
+	member = httptest.NewServer(newHealth(&memberFailing, &memberHits))
+	defer member.Close()
+	seed := httptest.NewServer(newHealth(&seedFailing, &seedHits))
+	defer seed.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:             []string{seed.Listener.Addr().String()},
+		AutoSyncEndpoints:     true,
+		SyncEndpointsInterval: 50 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+	// the syncs are sequential, the second query means the first one discovered the member
+	seedQueried := func(n int32) {
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&seedHits) >= n
+		}, 3*time.Second, 10*time.Millisecond)
+	}
+	seedQueried(2)
+
+	// the seed fails, the discovered member is asked instead of falling back to the seed
+	atomic.StoreInt32(&seedFailing, 1)
+	hits := atomic.LoadInt32(&memberHits)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&memberHits) > hits
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// the member fails too, the client falls back to the seed and forgets the member
+	atomic.StoreInt32(&memberFailing, 1)
+	seedQueried(atomic.LoadInt32(&seedHits) + 2)
+	hits = atomic.LoadInt32(&memberHits)
+	seedQueried(atomic.LoadInt32(&seedHits) + 2)
+	assert.Equal(t, hits, atomic.LoadInt32(&memberHits))
+}
+
+func TestClient_Reset(t *testing.T) {
+	// newServer records the paths of the websocket connections authorized by token
+	newServer := func(token string, connected *sync.Map) *httptest.Server {
+		upgrader := websocket.Upgrader{}
+		return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if token != "" && request.Header.Get("Authorization") != "Bearer "+token {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if !websocket.IsWebSocketUpgrade(request) {
+				writer.Write([]byte(`{"instances":[]}`))
+				return
+			}
+			conn, err := upgrader.Upgrade(writer, request, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			connected.Store(request.URL.Path, true)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+	}
+	var connected1, connected2 sync.Map
+	s1 := newServer("", &connected1)
+	defer s1.Close()
+	s2 := newServer("t2", &connected2)
+	defer s2.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{s1.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+	err = c.WatchMicroService("sid", func(*sc.MicroServiceInstanceChangedEvent) {})
+	assert.NoError(t, err)
+	h, err := c.WSHeartbeat("sid", "iid", nil)
+	assert.NoError(t, err)
+	defer h.Stop()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					c.Health()
+				}
+			}
+		}()
+	}
+	err = c.Reset(sc.Options{
+		Endpoints:  []string{s2.Listener.Addr().String()},
+		EnableAuth: true,
+		AuthToken:  "t2",
+		Timeout:    5 * time.Second,
+	})
+	close(stop)
+	wg.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, s2.Listener.Addr().String(), c.GetAddress())
+	_, err = c.Health()
+	assert.NoError(t, err)
+
+	t.Run("watches and heartbeats are re-established with the new options", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			_, watched := connected2.Load("/v4/default/registry/microservices/sid/watcher")
+			_, heartbeat := connected2.Load("/v4/default/registry/microservices/sid/instances/iid/heartbeat")
+			return watched && heartbeat
+		}, 5*time.Second, 100*time.Millisecond)
+		assert.Equal(t, sc.HeartbeatConnected, h.State())
+	})
+}
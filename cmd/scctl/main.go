@@ -0,0 +1,307 @@
+// scctl is the command line tool of service center client
+//
+//	scctl schema-check -addr 127.0.0.1:30100 -app default -service hello -version 2.0.0 -schema hello -file hello.yaml
+//	scctl export -addr 127.0.0.1:30100 -file backup.yaml
+//	scctl import -addr 127.0.0.1:30100 -file backup.yaml -dry-run
+//	scctl reconcile -addr 127.0.0.1:30100 -file services.yaml -dry-run
+//	scctl gc -addr 127.0.0.1:30100 -keep 3 -min-idle 168h -protect-tags lts= -state gc.json -audit gc.log -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-chassis/sc-client"
+	"github.com/go-chassis/sc-client/archive"
+)
+
+// command runs with the arguments after its name, and returns the exit code
+type command struct {
+	usage string
+	run   func(args []string) int
+}
+
+var commands = map[string]*command{
+	"schema-check": {usage: "check the schema against the previous version of the service", run: schemaCheck},
+	"export":       {usage: "export the services and their schemas, tags, rules and dependencies", run: exportArchive},
+	"import":       {usage: "import an exported archive, the existing resources are skipped", run: importArchive},
+	"reconcile":    {usage: "make the services the same as a manifest", run: reconcile},
+	"gc":           {usage: "unregister the stale versions of services without instances", run: collectGarbage},
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		printUsage()
+		os.Exit(2)
+	}
+	os.Exit(commands[os.Args[1]].run(os.Args[2:]))
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "usage: scctl <command> [flags]")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].usage)
+	}
+}
+
+// clientFlags are the flags to connect to service center shared by the commands
+type clientFlags struct {
+	addr  *string
+	ssl   *bool
+	token *string
+}
+
+func addClientFlags(fs *flag.FlagSet) *clientFlags {
+	return &clientFlags{
+		addr:  fs.String("addr", sc.DefaultAddr, "comma separated addresses of service center"),
+		ssl:   fs.Bool("ssl", false, "connect to service center with tls"),
+		token: fs.String("token", os.Getenv(sc.EnvAuthToken), "auth token, default is $"+sc.EnvAuthToken),
+	}
+}
+
+func (f *clientFlags) newClient() (*sc.Client, error) {
+	return sc.NewClient(sc.Options{
+		Endpoints:  strings.Split(*f.addr, ","),
+		EnableSSL:  *f.ssl,
+		EnableAuth: *f.token != "",
+		AuthToken:  *f.token,
+	})
+}
+
+func schemaCheck(args []string) int {
+	fs := flag.NewFlagSet("schema-check", flag.ExitOnError)
+	cf := addClientFlags(fs)
+	app := fs.String("app", "default", "app id of the service")
+	service := fs.String("service", "", "name of the service")
+	env := fs.String("env", "", "environment of the service")
+	version := fs.String("version", "", "the new version of the service")
+	previous := fs.String("previous", "", "the version to compare with, default is the latest one before -version")
+	schemaID := fs.String("schema", "", "schema id")
+	file := fs.String("file", "", "the schema file in yaml or json")
+	fs.Parse(args)
+	if *service == "" || *version == "" || *schemaID == "" || *file == "" {
+		fmt.Fprintln(os.Stderr, "-service, -version, -schema and -file are required")
+		fs.Usage()
+		return 2
+	}
+	content, err := os.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	c, err := cf.newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer c.Close()
+	changes, err := c.CheckSchemaCompatibility(*app, *service, *env, *version, *previous, *schemaID, content)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(changes) == 0 {
+		fmt.Println("no breaking change")
+		return 0
+	}
+	fmt.Printf("%d breaking changes:\n", len(changes))
+	for _, change := range changes {
+		fmt.Println("  " + change.String())
+	}
+	return 1
+}
+
+func exportArchive(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cf := addClientFlags(fs)
+	file := fs.String("file", "", "the archive file, default is stdout")
+	format := fs.String("format", string(archive.FormatYAML), "the format of the archive, yaml or json")
+	instances := fs.Bool("instances", false, "export the instances")
+	fs.Parse(args)
+	c, err := cf.newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer c.Close()
+	a, err := archive.Export(c, archive.ExportOptions{Instances: *instances})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out := os.Stdout
+	if *file != "" {
+		if out, err = os.Create(*file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	err = archive.Write(out, a, archive.Format(*format))
+	if out != os.Stdout {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "exported %d services\n", len(a.Services))
+	return 0
+}
+
+func importArchive(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	cf := addClientFlags(fs)
+	file := fs.String("file", "", "the archive file in yaml or json")
+	instances := fs.Bool("instances", false, "import the instances in the archive")
+	dryRun := fs.Bool("dry-run", false, "print the changes without applying them")
+	fs.Parse(args)
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "-file is required")
+		fs.Usage()
+		return 2
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	a, err := archive.Read(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	c, err := cf.newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer c.Close()
+	report, err := archive.Import(c, a, archive.ImportOptions{DryRun: *dryRun, Instances: *instances})
+	fmt.Print(report.String())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func reconcile(args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	cf := addClientFlags(fs)
+	file := fs.String("file", "", "the manifest file in yaml")
+	dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+	prune := fs.Bool("prune", false, "delete the undeclared services without instances in the apps of the manifest")
+	fs.Parse(args)
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "-file is required")
+		fs.Usage()
+		return 2
+	}
+	m, err := sc.LoadManifest(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	c, err := cf.newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer c.Close()
+	opts := []sc.ReconcileOption{}
+	if *dryRun {
+		opts = append(opts, sc.WithDryRun())
+	}
+	if *prune {
+		opts = append(opts, sc.WithPrune())
+	}
+	p, err := c.Reconcile(context.Background(), m, opts...)
+	if p != nil {
+		fmt.Print(p.String())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// parsePairs parses the comma separated pairs like k1=v1,k2
+func parsePairs(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	pairs := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			pairs[kv[0]] = kv[1]
+		} else {
+			pairs[kv[0]] = ""
+		}
+	}
+	return pairs
+}
+
+func collectGarbage(args []string) int {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	cf := addClientFlags(fs)
+	app := fs.String("app", "", "the app to collect, default is all the apps")
+	keep := fs.Int("keep", sc.DefaultGCKeepVersions, "the number of the latest versions kept for each service")
+	minIdle := fs.Duration("min-idle", sc.DefaultGCMinIdle, "how long a service has no instances before it is collected")
+	protectTags := fs.String("protect-tags", "", "comma separated tags like k1=v1,k2 protecting the services")
+	protectProperties := fs.String("protect-properties", "", "comma separated properties like k1=v1,k2 protecting the services")
+	auditFile := fs.String("audit", "", "the file the audit log is appended to, default is stderr")
+	stateFile := fs.String("state", "", "the file keeping the time the services are found without instances between the runs")
+	dryRun := fs.Bool("dry-run", false, "print the services to be collected without unregistering them")
+	fs.Parse(args)
+	audit := os.Stderr
+	if *auditFile != "" {
+		f, err := os.OpenFile(*auditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer f.Close()
+		audit = f
+	}
+	c, err := cf.newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer c.Close()
+	gc := c.NewGarbageCollector(sc.GCOptions{
+		AppID:             *app,
+		MinIdle:           *minIdle,
+		KeepVersions:      *keep,
+		ProtectTags:       parsePairs(*protectTags),
+		ProtectProperties: parsePairs(*protectProperties),
+		StateFile:         *stateFile,
+		DryRun:            *dryRun,
+		AuditLog:          audit,
+	})
+	records, err := gc.Collect(context.Background())
+	for _, r := range records {
+		if r.Decision == sc.GCDelete {
+			fmt.Printf("- %s (%s) %s\n", r.Service, r.ServiceID, r.Reason)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,221 @@
+package sc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chassis/openlog"
+)
+
+// ErrPreviousVersionNotExists means there is no version of the service before the given one
+var ErrPreviousVersionNotExists = errors.New("previous version of the service does not exist")
+
+// SchemaChange is a breaking change found by CompareSchemas
+type SchemaChange struct {
+	Path   string
+	Method string
+	// Parameter is the name of the parameter changed, empty if the change is not about a parameter
+	Parameter string
+	Message   string
+}
+
+func (c *SchemaChange) String() string {
+	s := c.Path
+	if c.Method != "" {
+		s = strings.ToUpper(c.Method) + " " + s
+	}
+	if c.Parameter != "" {
+		s += " parameter " + c.Parameter
+	}
+	return s + ": " + c.Message
+}
+
+// CompareSchemas returns the changes of current which break the consumers of previous, like removed paths,
+// changed parameter types and new required parameters, the result is sorted by path and method
+func CompareSchemas(previous, current *SchemaDocument) []*SchemaChange {
+	var changes []*SchemaChange
+	for path, operations := range previous.Paths {
+		currentOperations, ok := current.Paths[path]
+		if !ok {
+			changes = append(changes, &SchemaChange{Path: path, Message: "path is removed"})
+			continue
+		}
+		for method, op := range operations {
+			currentOp, ok := currentOperations[method]
+			if !ok {
+				changes = append(changes, &SchemaChange{Path: path, Method: method, Message: "operation is removed"})
+				continue
+			}
+			changes = append(changes, compareOperations(path, method, op, currentOp)...)
+		}
+	}
+	if previous.BasePath != current.BasePath {
+		changes = append(changes, &SchemaChange{Path: "/",
+			Message: fmt.Sprintf("base path is changed from %q to %q", previous.BasePath, current.BasePath)})
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Path != changes[j].Path {
+			return changes[i].Path < changes[j].Path
+		}
+		if changes[i].Method != changes[j].Method {
+			return changes[i].Method < changes[j].Method
+		}
+		return changes[i].Parameter < changes[j].Parameter
+	})
+	return changes
+}
+
+func compareOperations(path, method string, previous, current *SchemaOperation) []*SchemaChange {
+	var changes []*SchemaChange
+	add := func(param, format string, args ...interface{}) {
+		changes = append(changes, &SchemaChange{Path: path, Method: method, Parameter: param, Message: fmt.Sprintf(format, args...)})
+	}
+	previousParams := make(map[string]*SchemaParameter, len(previous.Parameters))
+	for _, p := range previous.Parameters {
+		previousParams[p.In+"/"+p.Name] = p
+	}
+	currentParams := make(map[string]bool, len(current.Parameters))
+	for _, p := range current.Parameters {
+		currentParams[p.In+"/"+p.Name] = true
+		old, ok := previousParams[p.In+"/"+p.Name]
+		switch {
+		case !ok && p.Required:
+			add(p.Name, "required parameter in %s is added", p.In)
+		case !ok:
+		case old.Type != p.Type:
+			add(p.Name, "type is changed from %q to %q", old.Type, p.Type)
+		case !old.Required && p.Required:
+			add(p.Name, "parameter becomes required")
+		}
+	}
+	for _, p := range previous.Parameters {
+		// a removed path parameter changes the url, the other removed parameters are ignored by the provider
+		if p.In == "path" && !currentParams[p.In+"/"+p.Name] {
+			add(p.Name, "path parameter is removed")
+		}
+	}
+	if !previous.RequestBodyRequired && current.RequestBodyRequired {
+		add("", "request body becomes required")
+	}
+	previousFields := make(map[string]bool, len(previous.RequestBodyFields))
+	for _, f := range previous.RequestBodyFields {
+		previousFields[f] = true
+	}
+	for _, f := range current.RequestBodyFields {
+		if !previousFields[f] {
+			add("", "field %s of request body becomes required", f)
+		}
+	}
+	return changes
+}
+
+// CheckSchemaCompatibility compares the local schema content with the schema registered for the previous version
+// of the service, and returns the breaking changes. If previousVersion is empty, the latest version before
+// the version of the service is used. A schema not existing in the previous version has no breaking change.
+// The versions are looked up in exactly env, the empty one means no environment, Options.Environment is not used.
+func (c *Client) CheckSchemaCompatibility(appID, microServiceName, env, version, previousVersion, schemaID string,
+	content []byte, opts ...CallOption) ([]*SchemaChange, error) {
+	current, err := ParseSchema(content)
+	if err != nil {
+		return nil, err
+	}
+	if previousVersion == "" {
+		previousVersion, err = c.previousVersion(appID, microServiceName, env, version, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	exact := append([]CallOption{WithExactEnvironment()}, opts...)
+	serviceID, err := c.GetMicroServiceID(appID, microServiceName, previousVersion, env, exact...)
+	if err != nil {
+		return nil, err
+	}
+	if serviceID == "" {
+		return nil, fmt.Errorf("%w: %s/%s/%s", ErrPreviousVersionNotExists, appID, microServiceName, previousVersion)
+	}
+	previous, err := c.GetSchemaDocument(serviceID, schemaID, opts...)
+	if err == ErrSchemaNotExists {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schema %s of version %s: %w", schemaID, previousVersion, err)
+	}
+	return CompareSchemas(previous, current), nil
+}
+
+// previousVersion returns the latest version of the service before version, the invalid versions are skipped
+func (c *Client) previousVersion(appID, microServiceName, env, version string, opts ...CallOption) (string, error) {
+	current, err := versionParts(version)
+	if err != nil {
+		return "", err
+	}
+	it := c.NewServiceIterator(ServiceFilter{AppID: appID, ServiceNamePrefix: microServiceName, Environment: env},
+		DefaultPageSize, opts...)
+	var previous []int
+	previousVersion := ""
+	for it.Next() {
+		ms := it.Service()
+		// the filter matches all the environments if env is empty
+		if ms.ServiceName != microServiceName || ms.Environment != env {
+			continue
+		}
+		v, err := versionParts(ms.Version)
+		if err != nil {
+			openlog.Warn(fmt.Sprintf("skip service %s: %s", ms.ServiceId, err.Error()))
+			continue
+		}
+		if compareVersionParts(v, current) >= 0 {
+			continue
+		}
+		if previous == nil || compareVersionParts(v, previous) > 0 {
+			previous, previousVersion = v, ms.Version
+		}
+	}
+	if err := it.Err(); err != nil {
+		return "", err
+	}
+	if previous == nil {
+		return "", fmt.Errorf("%w: %s/%s before %s", ErrPreviousVersionNotExists, appID, microServiceName, version)
+	}
+	return previousVersion, nil
+}
+
+// versionParts parses the dot separated numeric version like 1.0.2, it has at most 4 parts
+func versionParts(version string) ([]int, error) {
+	fields := strings.Split(version, ".")
+	if len(fields) > 4 {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	parts := make([]int, 0, len(fields))
+	for _, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || strings.HasPrefix(f, "+") {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
+
+// compareVersionParts compares the parsed versions, the missing parts are 0
+func compareVersionParts(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,191 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	previous, err := sc.ParseSchema([]byte(swagger2))
+	assert.NoError(t, err)
+
+	t.Run("compatible changes", func(t *testing.T) {
+		current, err := sc.ParseSchema([]byte(swagger2 + `
+  /health:
+    get:
+      responses:
+        200:
+          description: ok
+`))
+		assert.NoError(t, err)
+		assert.Empty(t, sc.CompareSchemas(previous, current))
+	})
+	t.Run("breaking changes", func(t *testing.T) {
+		current, err := sc.ParseSchema([]byte(`
+swagger: "2.0"
+info:
+  title: hello
+  version: 2.0.0
+basePath: /hello
+paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: integer
+        - name: verbose
+          in: query
+          required: true
+          type: boolean
+        - name: token
+          in: header
+          required: true
+          type: string
+      responses:
+        200:
+          description: ok
+`))
+		assert.NoError(t, err)
+		var changes []string
+		for _, c := range sc.CompareSchemas(previous, current) {
+			changes = append(changes, c.String())
+		}
+		assert.Equal(t, []string{
+			`GET /users/{id} parameter id: type is changed from "string" to "integer"`,
+			`GET /users/{id} parameter token: required parameter in header is added`,
+			`GET /users/{id} parameter verbose: parameter becomes required`,
+		}, changes)
+
+		empty, err := sc.ParseSchema([]byte("swagger: '2.0'\ninfo: {title: a, version: 1}\npaths: {}"))
+		assert.NoError(t, err)
+		changes = nil
+		for _, c := range sc.CompareSchemas(previous, empty) {
+			changes = append(changes, c.String())
+		}
+		assert.Equal(t, []string{`/: base path is changed from "/hello" to ""`, "/users/{id}: path is removed"}, changes)
+	})
+}
+
+func TestCompareSchemas_RequestBody(t *testing.T) {
+	const previous3 = `
+openapi: 3.0.0
+info: {title: hello, version: 1.0.0}
+paths:
+  /users:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/User"}
+      responses: {201: {description: created}}
+components:
+  schemas:
+    User:
+      required: [name]
+      properties: {name: {type: string}, email: {type: string}}
+`
+	const previous2 = `
+swagger: "2.0"
+info: {title: hello, version: 1.0.0}
+paths:
+  /users:
+    post:
+      parameters:
+        - {name: user, in: body, schema: {$ref: "#/definitions/User"}}
+      responses: {201: {description: created}}
+definitions:
+  User:
+    required: [name]
+    properties: {name: {type: string}, email: {type: string}}
+`
+	for name, previous := range map[string]string{"openapi 3": previous3, "swagger 2": previous2} {
+		t.Run(name, func(t *testing.T) {
+			p, err := sc.ParseSchema([]byte(previous))
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"name"}, p.Paths["/users"]["post"].RequestBodyFields)
+			c, err := sc.ParseSchema([]byte(strings.Replace(previous, "required: [name]", "required: [name, email, age]", 1)))
+			assert.NoError(t, err)
+			var changes []string
+			for _, change := range sc.CompareSchemas(p, c) {
+				changes = append(changes, change.String())
+			}
+			assert.Equal(t, []string{
+				"POST /users: field age of request body becomes required",
+				"POST /users: field email of request body becomes required",
+			}, changes)
+			assert.Empty(t, sc.CompareSchemas(c, p))
+		})
+	}
+}
+
+func TestClient_CheckSchemaCompatibility(t *testing.T) {
+	services := []*discovery.MicroService{
+		{ServiceId: "s1", AppId: "default", ServiceName: "hello", Version: "1.0.0"},
+		{ServiceId: "s2", AppId: "default", ServiceName: "hello", Version: "1.10.0"},
+		{ServiceId: "s3", AppId: "default", ServiceName: "hello", Version: "2.0.0"},
+		{ServiceId: "s4", AppId: "default", ServiceName: "hello-admin", Version: "1.20.0"},
+		// an invalid version is skipped instead of being taken as 0.0.0
+		{ServiceId: "s5", AppId: "default", ServiceName: "hello", Version: "1.x"},
+		// the versions in the other environments are not compared
+		{ServiceId: "s6", AppId: "default", ServiceName: "hello", Version: "1.15.0", Environment: "testing"},
+	}
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		path := request.URL.Path
+		switch {
+		case path == "/v4/default/registry/microservices":
+			b, _ := json.Marshal(&discovery.GetServicesResponse{Services: services})
+			writer.Write(b)
+		case path == "/v4/default/registry/existence":
+			for _, ms := range services {
+				q := request.URL.Query()
+				if ms.ServiceName == q.Get("serviceName") && ms.Version == q.Get("version") && ms.Environment == q.Get("env") {
+					b, _ := json.Marshal(&discovery.GetExistenceResponse{ServiceId: ms.ServiceId})
+					writer.Write(b)
+					return
+				}
+			}
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(`{"errorCode":"400012"}`))
+		case strings.HasSuffix(path, "/s2/schemas/hello"):
+			b, _ := json.Marshal(&discovery.GetSchemaResponse{Schema: swagger2})
+			writer.Write(b)
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:   []string{scServer.Listener.Addr().String()},
+		Environment: "testing",
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	removed := "swagger: '2.0'\ninfo: {title: a, version: 1}\nbasePath: /hello\npaths: {}"
+	// 1.10.0 is the previous version of 2.0.0
+	changes, err := c.CheckSchemaCompatibility("default", "hello", "", "2.0.0", "", "hello", []byte(removed))
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+
+	t.Run("the schema is new", func(t *testing.T) {
+		changes, err := c.CheckSchemaCompatibility("default", "hello", "", "2.0.0", "1.0.0", "hello", []byte(removed))
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+	t.Run("no previous version", func(t *testing.T) {
+		_, err := c.CheckSchemaCompatibility("default", "hello", "", "1.0.0", "", "hello", []byte(removed))
+		assert.True(t, errors.Is(err, sc.ErrPreviousVersionNotExists))
+	})
+}
@@ -0,0 +1,162 @@
+package sc
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/cari/rbac"
+	"github.com/go-chassis/openlog"
+)
+
+// Define the environment variables read by EnvCredentialProvider by default
+const (
+	EnvAuthToken    = "SC_AUTH_TOKEN"
+	EnvAuthUser     = "SC_AUTH_USER"
+	EnvAuthPassword = "SC_AUTH_PASSWORD"
+)
+
+// DefaultCredentialCheckInterval is the default interval FileCredentialProvider checks the file for changes
+const DefaultCredentialCheckInterval = 10 * time.Second
+
+// ErrNoCredentials means neither token nor user is provided when the authentication is enabled
+var ErrNoCredentials = errors.New("no credentials is provided")
+
+// Credentials is used to authenticate with service-center, Token takes precedence over User
+type Credentials struct {
+	Token string         `json:"token,omitempty"`
+	User  *rbac.AuthUser `json:"user,omitempty"`
+}
+
+func (c *Credentials) cacheKey() string {
+	return fmt.Sprintf("token:%s:%x", c.User.Username, sha256.Sum256([]byte(c.User.Password)))
+}
+
+// CredentialProvider supplies the credentials on each request, so that they can be rotated without restarting
+type CredentialProvider interface {
+	Credentials() (*Credentials, error)
+}
+
+// CredentialProviderFunc is an adapter to use a function as CredentialProvider
+type CredentialProviderFunc func() (*Credentials, error)
+
+// Credentials calls f()
+func (f CredentialProviderFunc) Credentials() (*Credentials, error) {
+	return f()
+}
+
+type staticCredentialProvider struct {
+	credentials *Credentials
+}
+
+func (p *staticCredentialProvider) Credentials() (*Credentials, error) {
+	return p.credentials, nil
+}
+
+// EnvCredentialProvider reads the credentials from environment variables on each request
+type EnvCredentialProvider struct {
+	TokenEnv    string
+	UserEnv     string
+	PasswordEnv string
+}
+
+// NewEnvCredentialProvider creates a provider reading EnvAuthToken, EnvAuthUser and EnvAuthPassword
+func NewEnvCredentialProvider() *EnvCredentialProvider {
+	return &EnvCredentialProvider{
+		TokenEnv:    EnvAuthToken,
+		UserEnv:     EnvAuthUser,
+		PasswordEnv: EnvAuthPassword,
+	}
+}
+
+// Credentials returns the token if it is set, otherwise the user
+func (p *EnvCredentialProvider) Credentials() (*Credentials, error) {
+	if token := os.Getenv(p.TokenEnv); token != "" {
+		return &Credentials{Token: token}, nil
+	}
+	user := os.Getenv(p.UserEnv)
+	if user == "" {
+		return nil, fmt.Errorf("%w, neither %s nor %s is set", ErrNoCredentials, p.TokenEnv, p.UserEnv)
+	}
+	return &Credentials{User: &rbac.AuthUser{Username: user, Password: os.Getenv(p.PasswordEnv)}}, nil
+}
+
+// FileCredentialProvider reads the credentials from a json file, like
+//
+//	{"token": "xxx"} or {"user": {"username": "root", "password": "xxx"}}
+//
+// the file is checked at most once per check interval, it is read again when its modification time or size changes
+type FileCredentialProvider struct {
+	path          string
+	checkInterval time.Duration
+	mutex         sync.Mutex
+	lastCheck     time.Time
+	modTime       time.Time
+	size          int64
+	credentials   *Credentials
+}
+
+// NewFileCredentialProvider creates a provider reading the file, it fails if the file is not valid.
+// The file is checked for changes at most once per checkInterval, default is DefaultCredentialCheckInterval
+func NewFileCredentialProvider(path string, checkInterval time.Duration) (*FileCredentialProvider, error) {
+	if checkInterval <= 0 {
+		checkInterval = DefaultCredentialCheckInterval
+	}
+	p := &FileCredentialProvider{path: path, checkInterval: checkInterval}
+	if _, err := p.Credentials(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Credentials returns the credentials in the file, if the changed file is not valid,
+// the last valid credentials are kept
+func (p *FileCredentialProvider) Credentials() (*Credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	if p.credentials != nil && now.Sub(p.lastCheck) < p.checkInterval {
+		return p.credentials, nil
+	}
+	p.lastCheck = now
+	err := p.reload()
+	if err == nil {
+		return p.credentials, nil
+	}
+	if p.credentials == nil {
+		return nil, err
+	}
+	openlog.Warn(fmt.Sprintf("reload credentials failed, keep the last ones: %s", err.Error()))
+	return p.credentials, nil
+}
+
+// reload reads the file if it is changed
+func (p *FileCredentialProvider) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return NewIOException(err, p.path)
+	}
+	if p.credentials != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return nil
+	}
+	body, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return NewIOException(err, p.path)
+	}
+	credentials := &Credentials{}
+	if err = json.Unmarshal(body, credentials); err != nil {
+		return NewJSONException(err, p.path)
+	}
+	if credentials.Token == "" && credentials.User == nil {
+		return fmt.Errorf("%w in %s", ErrNoCredentials, p.path)
+	}
+	p.credentials = credentials
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	return nil
+}
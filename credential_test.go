@@ -0,0 +1,102 @@
+package sc_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestFileCredentialProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"token":"t1"}`), 0600))
+
+	p, err := sc.NewFileCredentialProvider(path, 10*time.Millisecond)
+	assert.NoError(t, err)
+
+	var auth string
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		auth = request.Header.Get(sc.HeaderAuth)
+		writer.Write([]byte(`{}`))
+	}))
+	defer scServer.Close()
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:          []string{scServer.Listener.Addr().String()},
+		EnableAuth:         true,
+		CredentialProvider: p,
+	})
+	assert.NoError(t, err)
+
+	_, err = c.GetAllMicroServices()
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer t1", auth)
+
+	t.Run("rotate the token, should use the new one", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(`{"token":"token2"}`), 0600))
+		assert.Eventually(t, func() bool {
+			_, err = c.GetAllMicroServices()
+			return err == nil && auth == "Bearer token2"
+		}, time.Second, 20*time.Millisecond)
+	})
+	t.Run("broken file, should keep the last token", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(`{`), 0600))
+		os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+		time.Sleep(20 * time.Millisecond)
+		credentials, err := p.Credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, "token2", credentials.Token)
+	})
+	t.Run("changed within the check interval, should not read the file", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(`{"token":"t1"}`), 0600))
+		slow, err := sc.NewFileCredentialProvider(path, time.Hour)
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(`{"token":"token3"}`), 0600))
+		os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute))
+		credentials, err := slow.Credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, "t1", credentials.Token)
+	})
+}
+
+func TestClient_NilCredentials(t *testing.T) {
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(`{}`))
+	}))
+	defer scServer.Close()
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:  []string{scServer.Listener.Addr().String()},
+		EnableAuth: true,
+		CredentialProvider: sc.CredentialProviderFunc(func() (*sc.Credentials, error) {
+			return nil, nil
+		}),
+	})
+	assert.NoError(t, err)
+	_, err = c.GetAllMicroServices()
+	assert.Error(t, err)
+}
+
+func TestEnvCredentialProvider(t *testing.T) {
+	p := sc.NewEnvCredentialProvider()
+	t.Setenv(sc.EnvAuthToken, "")
+	t.Setenv(sc.EnvAuthUser, "")
+	_, err := p.Credentials()
+	assert.Error(t, err)
+
+	t.Setenv(sc.EnvAuthUser, "root")
+	t.Setenv(sc.EnvAuthPassword, "pwd")
+	credentials, err := p.Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "root", credentials.User.Username)
+
+	t.Setenv(sc.EnvAuthToken, "token")
+	credentials, err = p.Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", credentials.Token)
+}
@@ -0,0 +1,96 @@
+package sc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-chassis/openlog"
+)
+
+const (
+	// DefaultDrainPollInterval is the default interval of checking whether the status change is applied during Drain
+	DefaultDrainPollInterval = 500 * time.Millisecond
+	// DefaultDrainPropagationDelay is the default time given to watchers to receive the status change during Drain
+	DefaultDrainPropagationDelay = 3 * time.Second
+)
+
+// SetInstanceStatus updates the status of the instance to one of the typed statuses
+func (c *Client) SetInstanceStatus(microServiceID, microServiceInstanceID string, status InstanceStatus) (bool, error) {
+	if !status.Valid() {
+		return false, fmt.Errorf("invalid instance status: %s", status)
+	}
+	return c.UpdateMicroServiceInstanceStatus(microServiceID, microServiceInstanceID, string(status))
+}
+
+// Drain stops the traffic to an instance before it is unregistered.
+// It sets the instance OUTOFSERVICE, waits until service-center applied the change and
+// the watchers had the propagation delay to receive it, or until grace elapses, then unregisters the instance.
+// If ctx is done before that, the instance stays OUTOFSERVICE and ctx.Err() is returned.
+func (c *Client) Drain(ctx context.Context, microServiceID, microServiceInstanceID string, grace time.Duration,
+	opts ...DrainOption) error {
+	dopts := &DrainOptions{}
+	for _, opt := range opts {
+		opt(dopts)
+	}
+	if dopts.PollInterval <= 0 {
+		dopts.PollInterval = DefaultDrainPollInterval
+	}
+	if dopts.PropagationDelay <= 0 {
+		dopts.PropagationDelay = DefaultDrainPropagationDelay
+	}
+	deadline := time.NewTimer(grace)
+	defer deadline.Stop()
+	if _, err := c.SetInstanceStatus(microServiceID, microServiceInstanceID, InstanceOutOfService); err != nil {
+		return err
+	}
+	if err := c.waitStatusApplied(ctx, deadline.C, dopts, microServiceID, microServiceInstanceID, InstanceOutOfService); err != nil {
+		return err
+	}
+	if _, err := c.UnregisterMicroServiceInstance(microServiceID, microServiceInstanceID); err != nil {
+		return err
+	}
+	openlog.Info(fmt.Sprintf("instance %s is drained", microServiceInstanceID))
+	return nil
+}
+
+// waitStatusApplied returns nil when the status is applied and propagated, or the deadline is reached
+func (c *Client) waitStatusApplied(ctx context.Context, deadline <-chan time.Time, dopts *DrainOptions,
+	microServiceID, microServiceInstanceID string, status InstanceStatus) error {
+	ticker := time.NewTicker(dopts.PollInterval)
+	defer ticker.Stop()
+	for !c.statusApplied(microServiceID, microServiceInstanceID, status) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			openlog.Warn(fmt.Sprintf("grace period elapsed before the status of instance %s is applied", microServiceInstanceID))
+			return nil
+		case <-ticker.C:
+		}
+	}
+	propagated := time.NewTimer(dopts.PropagationDelay)
+	defer propagated.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-deadline:
+	case <-propagated.C:
+	}
+	return nil
+}
+
+func (c *Client) statusApplied(microServiceID, microServiceInstanceID string, status InstanceStatus) bool {
+	// the instance may be ejected as an outlier, it must be found anyway
+	instances, err := c.getMicroServiceInstances(microServiceID, microServiceID, &CallOptions{WithoutRevision: true})
+	if err != nil {
+		openlog.Warn(fmt.Sprintf("query instances of %s failed: %s", microServiceID, err.Error()))
+		return false
+	}
+	for _, instance := range instances {
+		if instance.InstanceId == microServiceInstanceID {
+			return instance.Status == string(status)
+		}
+	}
+	return false
+}
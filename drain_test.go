@@ -0,0 +1,62 @@
+package sc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestClient_Drain(t *testing.T) {
+	var mutex sync.Mutex
+	status := sc.MSInstanceUP
+	unregistered := false
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch request.Method {
+		case http.MethodPut:
+			status = request.URL.Query().Get("value")
+		case http.MethodDelete:
+			unregistered = true
+		case http.MethodGet:
+			b, _ := json.Marshal(&discovery.GetInstancesResponse{
+				Instances: []*discovery.MicroServiceInstance{
+					{InstanceId: "iid", ServiceId: "sid", Status: status},
+					{InstanceId: "other", ServiceId: "sid", Status: sc.MSInstanceUP},
+				},
+			})
+			writer.Write(b)
+		}
+	}))
+	defer scServer.Close()
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:        []string{scServer.Listener.Addr().String()},
+		OutlierDetection: &sc.OutlierDetectionOptions{ConsecutiveFailures: 1},
+	})
+	assert.NoError(t, err)
+	// the draining instance is ejected, its status is still found
+	c.ReportCallResult("iid", errors.New("refused"), 0)
+
+	_, err = c.SetInstanceStatus("sid", "iid", "RUNNING")
+	assert.Error(t, err)
+
+	start := time.Now()
+	err = c.Drain(context.Background(), "sid", "iid", 10*time.Second,
+		sc.WithDrainPollInterval(50*time.Millisecond), sc.WithDrainPropagationDelay(100*time.Millisecond))
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	mutex.Lock()
+	assert.Equal(t, string(sc.InstanceOutOfService), status)
+	assert.True(t, unregistered)
+	mutex.Unlock()
+}
@@ -0,0 +1,174 @@
+package sc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chassis/openlog"
+)
+
+const (
+	// DefaultPeerCheckInterval is the default interval of checking the engines in dual engine mode
+	DefaultPeerCheckInterval = 10 * time.Second
+	// DefaultEngineFailureThreshold is the default number of consecutive failed checks to fail over
+	DefaultEngineFailureThreshold = 3
+)
+
+// DualEngineOptions is the options of dual engine mode
+type DualEngineOptions struct {
+	// CheckInterval is the interval of checking the current engine and its peers, default is DefaultPeerCheckInterval
+	CheckInterval time.Duration
+	// FailureThreshold is the number of consecutive failed checks of the current engine to fail over,
+	// default is DefaultEngineFailureThreshold
+	FailureThreshold int
+	// OnFailover receives the event when the client switches to a peer engine
+	OnFailover func(e *FailoverEvent)
+}
+
+// FailoverEvent is a struct to store the engine failover information
+type FailoverEvent struct {
+	// Peer is the name of the engine switched to
+	Peer   string
+	From   []string
+	To     []string
+	Reason string
+}
+
+// Healthy returns whether the peer is able to serve
+func (p *Peer) Healthy() bool {
+	return strings.EqualFold(p.Status, "up") || strings.EqualFold(p.Status, "connected")
+}
+
+// Peers returns the peer engines reported by the current engine in the last check
+func (c *Client) Peers() []*Peer {
+	c.peerMutex.Lock()
+	defer c.peerMutex.Unlock()
+	return append([]*Peer{}, c.peers...)
+}
+
+// watchEngines checks the engines periodically until the client is closed
+func (c *Client) watchEngines(opt DualEngineOptions) {
+	interval := opt.CheckInterval
+	if interval <= 0 {
+		interval = DefaultPeerCheckInterval
+	}
+	if opt.FailureThreshold <= 0 {
+		opt.FailureThreshold = DefaultEngineFailureThreshold
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		failures = c.checkEngines(opt, failures)
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkEngines refreshes the peers while the current engine is healthy, otherwise fails over to a healthy peer
+// once the engine failed FailureThreshold checks in a row. It returns the consecutive failures of the current engine.
+func (c *Client) checkEngines(opt DualEngineOptions, failures int) int {
+	_, err := c.Health()
+	if err == nil {
+		resp, err := c.CheckPeerStatus()
+		if err != nil {
+			openlog.Debug(fmt.Sprintf("check peer status failed: %s", err.Error()))
+			return 0
+		}
+		c.peerMutex.Lock()
+		c.peers = resp.Peers
+		c.peerMutex.Unlock()
+		return 0
+	}
+	failures++
+	openlog.Warn(fmt.Sprintf("current engine is unhealthy(%d/%d): %s", failures, opt.FailureThreshold, err.Error()))
+	if failures < opt.FailureThreshold {
+		return failures
+	}
+	peer, endpoints := c.healthyPeer()
+	if peer == nil {
+		openlog.Error("no healthy peer engine to fail over")
+		return failures
+	}
+	c.failover(opt, peer, endpoints, err.Error())
+	return 0
+}
+
+func (c *Client) healthyPeer() (*Peer, []string) {
+	c.peerMutex.Lock()
+	defer c.peerMutex.Unlock()
+	for _, peer := range c.peers {
+		if peer == nil || !peer.Healthy() {
+			continue
+		}
+		if endpoints := peerAddresses(peer.Endpoints); len(endpoints) != 0 {
+			return peer, endpoints
+		}
+	}
+	return nil, nil
+}
+
+// peerAddresses converts the endpoints of peer, like http://127.0.0.1:30100, to addresses of the pool
+func peerAddresses(endpoints []string) []string {
+	var addresses []string
+	for _, ep := range endpoints {
+		if !strings.Contains(ep, "://") {
+			addresses = mergeEndpoints(addresses, []string{ep})
+			continue
+		}
+		u, err := url.Parse(ep)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		addresses = mergeEndpoints(addresses, []string{u.Host})
+	}
+	return addresses
+}
+
+// failover switches the address pool to the peer engine, and re-establishes the websocket connections
+// of watches and heartbeats on it
+func (c *Client) failover(opt DualEngineOptions, peer *Peer, endpoints []string, reason string) {
+	c.poolMutex.Lock()
+	from := c.activeEndpoints
+	if from == nil {
+		from = c.options().Endpoints
+	}
+	c.activeEndpoints = endpoints
+	c.syncedEndpoints = nil
+	c.discoveredEndpoints = nil
+	c.addressPool().ResetAddress(endpoints)
+	c.poolMutex.Unlock()
+
+	// the peers are reported again by the new engine
+	c.peerMutex.Lock()
+	c.peers = nil
+	c.peerMutex.Unlock()
+
+	openlog.Warn(fmt.Sprintf("fail over from %v to peer engine %s %v", from, peer.Name, endpoints))
+	c.reconnectWebsockets()
+	if opt.OnFailover != nil {
+		opt.OnFailover(&FailoverEvent{
+			Peer:   peer.Name,
+			From:   from,
+			To:     endpoints,
+			Reason: reason,
+		})
+	}
+}
+
+// reconnectWebsockets closes the websocket connections of watches and heartbeats,
+// they are re-established immediately by their own goroutines with the current address
+func (c *Client) reconnectWebsockets() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for id, conn := range c.conns {
+		if err := conn.Close(); err != nil {
+			openlog.Warn(fmt.Sprintf("close websocket connection of %s failed: %s", id, err.Error()))
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestClient_DualEngine(t *testing.T) {
+	engine2 := newInstancesServer()
+	defer engine2.Close()
+	var blips int32
+	engine1 := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/v4/default/registry/health" && atomic.AddInt32(&blips, -1) >= 0 {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var resp interface{} = &discovery.GetInstancesResponse{}
+		if request.URL.Path == sc.PeerHealthPath {
+			resp = &sc.PeerStatusResp{Peers: []*sc.Peer{{
+				Name:      "engine2",
+				Endpoints: []string{"http://" + engine2.Listener.Addr().String()},
+				Status:    "UP",
+			}}}
+		}
+		b, _ := json.Marshal(resp)
+		writer.Write(b)
+	}))
+
+	events := make(chan *sc.FailoverEvent, 1)
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{engine1.Listener.Addr().String()},
+		DualEngine: &sc.DualEngineOptions{
+			CheckInterval: 100 * time.Millisecond,
+			OnFailover: func(e *sc.FailoverEvent) {
+				events <- e
+			},
+		},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+	time.Sleep(300 * time.Millisecond)
+	assert.Len(t, c.Peers(), 1)
+
+	// a single failed check does not fail over
+	atomic.StoreInt32(&blips, 1)
+	select {
+	case e := <-events:
+		t.Fatalf("should not fail over to %s", e.Peer)
+	case <-time.After(500 * time.Millisecond):
+	}
+	assert.Equal(t, engine1.Listener.Addr().String(), c.GetAddress())
+
+	engine1.Close()
+	select {
+	case e := <-events:
+		assert.Equal(t, "engine2", e.Peer)
+		assert.Equal(t, []string{engine2.Listener.Addr().String()}, e.To)
+	case <-time.After(3 * time.Second):
+		t.Fatal("should fail over to engine2")
+	}
+	assert.Equal(t, engine2.Listener.Addr().String(), c.GetAddress())
+}
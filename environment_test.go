@@ -0,0 +1,70 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestClient_Environment(t *testing.T) {
+	var mutex sync.Mutex
+	var envs []string
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		var b []byte
+		switch request.URL.Path {
+		case "/v4/default/registry/instances/action":
+			body, _ := ioutil.ReadAll(request.Body)
+			var r discovery.BatchFindInstancesRequest
+			json.Unmarshal(body, &r)
+			for _, key := range r.Services {
+				envs = append(envs, key.Service.Environment)
+			}
+			b, _ = json.Marshal(&discovery.BatchFindInstancesResponse{})
+		case "/v4/default/registry/existence":
+			envs = append(envs, request.URL.Query().Get("env"))
+			b, _ = json.Marshal(&discovery.GetExistenceResponse{ServiceId: "sid"})
+		default:
+			envs = append(envs, request.URL.Query().Get("env"))
+			b, _ = json.Marshal(&discovery.GetInstancesResponse{})
+		}
+		writer.Write(b)
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:   []string{scServer.Listener.Addr().String()},
+		Environment: "testing",
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.FindInstances("consumer", "default", "provider")
+	assert.NoError(t, err)
+	_, err = c.FindInstances("consumer", "default", "provider", sc.WithEnvironment("development"))
+	assert.NoError(t, err)
+	_, err = c.GetMicroServiceID("default", "provider", "1.0.0", "")
+	assert.NoError(t, err)
+	_, err = c.GetMicroServiceID("default", "provider", "1.0.0", "production")
+	assert.NoError(t, err)
+	keys := []*discovery.FindService{
+		{Service: &discovery.MicroServiceKey{AppId: "default", ServiceName: "a", Version: "latest"}},
+		{Service: &discovery.MicroServiceKey{AppId: "default", ServiceName: "b", Version: "latest", Environment: "acceptance"}},
+	}
+	_, err = c.BatchFindInstances("consumer", keys)
+	assert.NoError(t, err)
+	assert.Equal(t, "", keys[0].Service.Environment, "the keys of the caller are not changed")
+
+	mutex.Lock()
+	assert.Equal(t, []string{"testing", "development", "testing", "production", "testing", "acceptance"}, envs)
+	mutex.Unlock()
+}
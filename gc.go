@@ -0,0 +1,325 @@
+package sc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+const (
+	// DefaultGCMinIdle is the default time a service has no instances before it is collected
+	DefaultGCMinIdle = 7 * 24 * time.Hour
+	// DefaultGCKeepVersions is the default number of the latest versions kept for each service
+	DefaultGCKeepVersions = 3
+)
+
+// the decisions of garbage collection
+const (
+	GCDelete = "delete"
+	GCKeep   = "keep"
+)
+
+// GCOptions is the options of the garbage collection of services
+type GCOptions struct {
+	// AppID limits the collection to an app, all the apps are collected if it is empty
+	AppID string
+	// MinIdle is how long a service has no instances before it is collected, DefaultGCMinIdle if it is 0
+	MinIdle time.Duration
+	// KeepVersions is the number of the latest versions kept for each service name in an app and environment,
+	// no matter whether they have instances, DefaultGCKeepVersions if it is 0
+	KeepVersions int
+	// ProtectTags protects the services having any of the tags, an empty value matches any value
+	ProtectTags map[string]string
+	// ProtectProperties protects the services having any of the properties, an empty value matches any value
+	ProtectProperties map[string]string
+	// StateFile keeps the time each service is found without instances between the runs of collectors,
+	// the state is only kept in the collector if it is empty
+	StateFile string
+	// DryRun reports the services to be collected without deleting them or saving the state
+	DryRun bool
+	// AuditLog receives a JSON line for each service deleted or to be deleted in dry run
+	AuditLog io.Writer
+}
+
+// GCRecord is the decision about a service
+type GCRecord struct {
+	Time      time.Time `json:"time"`
+	ServiceID string    `json:"serviceId"`
+	// Service is the key of the service, like default/hello/1.0.0@development
+	Service string `json:"service"`
+	// Decision is GCDelete or GCKeep
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	DryRun   bool   `json:"dryRun,omitempty"`
+	// Error is the error of the deletion
+	Error string `json:"error,omitempty"`
+}
+
+// GarbageCollector deletes the stale versions of services. Service center does not record when a service
+// lost its instances, so the collector remembers the time it first finds a service without instances,
+// in StateFile if it is set. A service the collector knows nothing about is idle since its modification time.
+type GarbageCollector struct {
+	c     *Client
+	opt   GCOptions
+	mutex sync.Mutex
+	// idleSince is the time each service is found without instances, zero if it had instances
+	idleSince map[string]time.Time
+}
+
+// NewGarbageCollector returns a collector of the services registered in service center
+func (c *Client) NewGarbageCollector(opt GCOptions) *GarbageCollector {
+	if opt.MinIdle == 0 {
+		opt.MinIdle = DefaultGCMinIdle
+	}
+	if opt.KeepVersions == 0 {
+		opt.KeepVersions = DefaultGCKeepVersions
+	}
+	return &GarbageCollector{c: c, opt: opt, idleSince: make(map[string]time.Time)}
+}
+
+// gcService is a service with its parsed version
+type gcService struct {
+	detail  *discovery.ServiceDetail
+	version Version
+}
+
+// Collect unregisters the services which have no instances for longer than MinIdle, except the latest versions
+// and the protected ones. It returns the decisions about all the services,
+// the failed deletions are recorded with errors and counted in the returned error.
+// In dry run, neither the services are deleted nor the state is saved.
+func (gc *GarbageCollector) Collect(ctx context.Context) ([]*GCRecord, error) {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+	if gc.opt.StateFile != "" {
+		if err := gc.loadState(); err != nil {
+			return nil, err
+		}
+	}
+	services, err := gc.c.GetAllResources(governOptions([]GovernOption{GovernInstances, GovernTags}))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var records []*GCRecord
+	// the state of the services not collected, like the ones of other apps, is kept
+	idleSince := make(map[string]time.Time, len(gc.idleSince))
+	groups := make(map[string][]*gcService)
+	for _, detail := range services {
+		ms := detail.MicroService
+		if ms == nil {
+			continue
+		}
+		if t, ok := gc.idleSince[ms.ServiceId]; ok {
+			idleSince[ms.ServiceId] = t
+		}
+		if ms.ServiceName == serviceCenterName || (gc.opt.AppID != "" && ms.AppId != gc.opt.AppID) {
+			continue
+		}
+		v, err := ParseVersion(ms.Version)
+		if err != nil {
+			r := gc.record(ms, now)
+			r.Reason = "invalid version"
+			records = append(records, r)
+			continue
+		}
+		idleSince[ms.ServiceId] = gc.idleTime(detail, now)
+		group := ms.AppId + "/" + ms.ServiceName + "@" + ms.Environment
+		groups[group] = append(groups[group], &gcService{detail: detail, version: v})
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failed := 0
+	for _, name := range names {
+		versions := groups[name]
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].version.Compare(versions[j].version) > 0
+		})
+		for i, s := range versions {
+			if err := ctx.Err(); err != nil {
+				return records, err
+			}
+			r := gc.decide(s.detail, i, idleSince[s.detail.MicroService.ServiceId], now)
+			records = append(records, r)
+			if r.Decision != GCDelete {
+				continue
+			}
+			if !gc.opt.DryRun {
+				if _, err := gc.c.UnregisterMicroService(r.ServiceID); err != nil {
+					r.Error = err.Error()
+					failed++
+				} else {
+					delete(idleSince, r.ServiceID)
+				}
+			}
+			gc.audit(r)
+		}
+	}
+	if !gc.opt.DryRun {
+		gc.idleSince = idleSince
+		if gc.opt.StateFile != "" {
+			if err := gc.saveState(); err != nil {
+				return records, err
+			}
+		}
+	}
+	if failed != 0 {
+		return records, fmt.Errorf("%d of the services failed to be unregistered", failed)
+	}
+	return records, nil
+}
+
+func (gc *GarbageCollector) record(ms *discovery.MicroService, now time.Time) *GCRecord {
+	return &GCRecord{
+		Time:      now,
+		ServiceID: ms.ServiceId,
+		Service: serviceKeyString(&discovery.MicroServiceKey{AppId: ms.AppId, ServiceName: ms.ServiceName,
+			Version: ms.Version, Environment: ms.Environment}),
+		Decision: GCKeep,
+	}
+}
+
+// idleTime returns the time the service is idle since, zero if it has instances
+func (gc *GarbageCollector) idleTime(detail *discovery.ServiceDetail, now time.Time) time.Time {
+	if len(detail.Instances) != 0 {
+		return time.Time{}
+	}
+	t, ok := gc.idleSince[detail.MicroService.ServiceId]
+	if !ok {
+		// unknown to the collector, the service is idle at least since it was modified
+		t = unixTime(detail.MicroService.ModTimestamp)
+		if t.IsZero() {
+			t = unixTime(detail.MicroService.Timestamp)
+		}
+	}
+	// zero if it had instances at the last collection
+	if t.IsZero() || t.After(now) {
+		return now
+	}
+	return t
+}
+
+// decide returns the decision about the service, rank is the order of its version from the latest
+func (gc *GarbageCollector) decide(detail *discovery.ServiceDetail, rank int, idleSince, now time.Time) *GCRecord {
+	ms := detail.MicroService
+	r := gc.record(ms, now)
+	if len(detail.Instances) != 0 {
+		r.Reason = fmt.Sprintf("%d instances", len(detail.Instances))
+		return r
+	}
+	if rank < gc.opt.KeepVersions {
+		r.Reason = fmt.Sprintf("one of the latest %d versions", gc.opt.KeepVersions)
+		return r
+	}
+	if k, ok := protected(detail.Tags, gc.opt.ProtectTags); ok {
+		r.Reason = "protected by tag " + k
+		return r
+	}
+	if k, ok := protected(ms.Properties, gc.opt.ProtectProperties); ok {
+		r.Reason = "protected by property " + k
+		return r
+	}
+	if idle := now.Sub(idleSince); idle < gc.opt.MinIdle {
+		r.Reason = fmt.Sprintf("no instances for %s, less than %s", idle.Round(time.Minute), gc.opt.MinIdle)
+		return r
+	}
+	r.Decision = GCDelete
+	r.DryRun = gc.opt.DryRun
+	r.Reason = fmt.Sprintf("no instances for longer than %s", gc.opt.MinIdle)
+	return r
+}
+
+// loadState reads StateFile, which maps the service ids to the unix time in seconds
+// they are found without instances, 0 if they had instances. A missing file is an empty state.
+func (gc *GarbageCollector) loadState() error {
+	b, err := ioutil.ReadFile(gc.opt.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return NewIOException(err, gc.opt.StateFile)
+	}
+	state := make(map[string]int64)
+	if err := json.Unmarshal(b, &state); err != nil {
+		return NewJSONException(err, gc.opt.StateFile)
+	}
+	gc.idleSince = make(map[string]time.Time, len(state))
+	for id, seconds := range state {
+		gc.idleSince[id] = time.Time{}
+		if seconds > 0 {
+			gc.idleSince[id] = time.Unix(seconds, 0)
+		}
+	}
+	return nil
+}
+
+// saveState writes StateFile by renaming a temporary file, so that a broken write does not lose the state
+func (gc *GarbageCollector) saveState() error {
+	state := make(map[string]int64, len(gc.idleSince))
+	for id, t := range gc.idleSince {
+		state[id] = 0
+		if !t.IsZero() {
+			state[id] = t.Unix()
+		}
+	}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return NewJSONException(err, gc.opt.StateFile)
+	}
+	tmp := gc.opt.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return NewIOException(err, tmp)
+	}
+	if err := os.Rename(tmp, gc.opt.StateFile); err != nil {
+		return NewIOException(err, gc.opt.StateFile)
+	}
+	return nil
+}
+
+func (gc *GarbageCollector) audit(r *GCRecord) {
+	if gc.opt.AuditLog == nil {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	gc.opt.AuditLog.Write(append(b, '\n'))
+}
+
+// protected returns the first key of protect matched by values in order
+func protected(values, protect map[string]string) (string, bool) {
+	keys := make([]string, 0, len(protect))
+	for k := range protect {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := values[k]
+		if ok && (protect[k] == "" || protect[k] == v) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// unixTime parses the timestamp in seconds, zero if it is invalid
+func unixTime(timestamp string) time.Time {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
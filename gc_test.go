@@ -0,0 +1,105 @@
+package sc_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+	"github.com/go-chassis/sc-client/internal/scfake"
+)
+
+func TestGarbageCollector_Collect(t *testing.T) {
+	s := scfake.New()
+	defer s.Close()
+	old := fmt.Sprint(time.Now().Add(-30 * 24 * time.Hour).Unix())
+	recent := fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+	ids := make(map[string]string)
+	for _, v := range []string{"0.9.0", "1.0.0", "1.1.0", "1.2.0", "1.10.0", "2.0.0", "3.0.0", "bad"} {
+		ms := &discovery.MicroService{AppId: "default", ServiceName: "hello", Version: v, ModTimestamp: old}
+		switch v {
+		case "0.9.0":
+			// the creation time is used if the modification time is missing
+			ms.ModTimestamp, ms.Timestamp = "", old
+		case "1.1.0":
+			ms.ModTimestamp = recent
+		case "1.2.0":
+			ms.Properties = map[string]string{"pinned": "yes"}
+		}
+		ids[v] = s.AddService(ms)
+	}
+	s.AddService(&discovery.MicroService{AppId: "default", ServiceName: "SERVICECENTER", Version: "0.1.0", ModTimestamp: old})
+	s.Lock()
+	s.Services[ids["1.0.0"]].Tags["keep"] = "false"
+	s.Services[ids["1.10.0"]].Instances = []*discovery.MicroServiceInstance{{InstanceId: "i"}}
+	s.Unlock()
+
+	c, err := sc.NewClient(sc.Options{Endpoints: []string{s.Addr()}})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var audit bytes.Buffer
+	opt := sc.GCOptions{
+		KeepVersions:      2,
+		ProtectTags:       map[string]string{"keep": "true"},
+		ProtectProperties: map[string]string{"pinned": ""},
+		StateFile:         filepath.Join(t.TempDir(), "gc.json"),
+		DryRun:            true,
+		AuditLog:          &audit,
+	}
+	records, err := c.NewGarbageCollector(opt).Collect(context.Background())
+	assert.NoError(t, err)
+	decisions := make(map[string]string)
+	for _, r := range records {
+		decisions[r.Service] = r.Decision + ": " + r.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"default/hello/3.0.0":  "keep: one of the latest 2 versions",
+		"default/hello/2.0.0":  "keep: one of the latest 2 versions",
+		"default/hello/1.10.0": "keep: 1 instances",
+		"default/hello/1.2.0":  "keep: protected by property pinned",
+		"default/hello/1.1.0":  "keep: no instances for 1h0m0s, less than 168h0m0s",
+		"default/hello/1.0.0":  "delete: no instances for longer than 168h0m0s",
+		"default/hello/0.9.0":  "delete: no instances for longer than 168h0m0s",
+		"default/hello/bad":    "keep: invalid version",
+	}, decisions)
+	assert.Equal(t, 2, strings.Count(audit.String(), "\n"))
+	assert.Contains(t, audit.String(), `"dryRun":true`)
+	s.Lock()
+	assert.Empty(t, s.Requests)
+	s.Unlock()
+	_, err = os.Stat(opt.StateFile)
+	assert.True(t, os.IsNotExist(err))
+
+	opt.DryRun = false
+	_, err = c.NewGarbageCollector(opt).Collect(context.Background())
+	assert.NoError(t, err)
+	s.Lock()
+	// only the deletions are sent to service center, the services are not marked
+	assert.ElementsMatch(t, []string{
+		"DELETE /v4/default/registry/microservices/" + ids["0.9.0"],
+		"DELETE /v4/default/registry/microservices/" + ids["1.0.0"],
+	}, s.Requests)
+	assert.Equal(t, 7, len(s.Services))
+	s.Services[ids["1.10.0"]].Instances = nil
+	s.Unlock()
+
+	// another run, like another scctl gc, knows 1.10.0 lost its instances just now from the state file
+	opt.MinIdle = 30 * time.Minute
+	records, err = c.NewGarbageCollector(opt).Collect(context.Background())
+	assert.NoError(t, err)
+	decisions = make(map[string]string)
+	for _, r := range records {
+		decisions[r.Service] = r.Decision + ": " + r.Reason
+	}
+	assert.Equal(t, "keep: no instances for 0s, less than 30m0s", decisions["default/hello/1.10.0"])
+	assert.Equal(t, "delete: no instances for longer than 30m0s", decisions["default/hello/1.1.0"])
+}
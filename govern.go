@@ -0,0 +1,178 @@
+package sc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+// GovernOption is a kind of detail included in the result of governance APIs
+type GovernOption string
+
+const (
+	// GovernInstances includes the instances of service
+	GovernInstances GovernOption = "instances"
+	// GovernSchemas includes the schemas of service
+	GovernSchemas GovernOption = "schemas"
+	// GovernTags includes the tags of service
+	GovernTags GovernOption = "tags"
+	// GovernRules includes the black and white list rules of service
+	GovernRules GovernOption = "rules"
+	// GovernDependencies includes the providers and consumers of service
+	GovernDependencies GovernOption = "dependencies"
+	// GovernStatistics includes the statistics
+	GovernStatistics GovernOption = "statistics"
+	// GovernAll includes all the details
+	GovernAll GovernOption = "all"
+)
+
+// AppServices is the services registered in an application
+type AppServices struct {
+	AppID    string
+	Services []*discovery.ServiceDetail
+}
+
+// governOptions formats the options query of governance APIs
+func governOptions(details []GovernOption) string {
+	options := make([]string, 0, len(details))
+	for _, d := range details {
+		options = append(options, string(d))
+	}
+	return strings.Join(options, ",")
+}
+
+// GetServiceDetail returns the service with the given details, such as instances, schemas and tags
+func (c *Client) GetServiceDetail(microServiceID string, details []GovernOption, opts ...CallOption) (*discovery.ServiceDetail, error) {
+	if microServiceID == "" {
+		return nil, errors.New("invalid micro service ID")
+	}
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s", GovernAPIPATH, MicroservicePath, microServiceID), []URLParameter{
+		{"options": governOptions(details)},
+	}, copts)
+	resp, err := c.httpDo("GET", url, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("GetServiceDetail failed, response is empty, MicroServiceId: %s", microServiceID)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewIOException(err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var response discovery.GetServiceDetailResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, NewJSONException(err, string(body))
+		}
+		return response.Service, nil
+	}
+	if resp.StatusCode == 400 && strings.Contains(string(body), "\"errorCode\":\"400012\"") {
+		return nil, ErrMicroServiceNotExists
+	}
+	return nil, fmt.Errorf("GetServiceDetail failed, MicroServiceId: %s, response StatusCode: %d, response body: %s",
+		microServiceID, resp.StatusCode, string(body))
+}
+
+// GetStatistics returns the count of services, instances and applications in the service-center
+func (c *Client) GetStatistics(opts ...CallOption) (*discovery.Statistics, error) {
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	response, err := c.getServicesInfo("GetStatistics", []URLParameter{
+		{"options": string(GovernStatistics)},
+		{"countOnly": "true"},
+	}, copts)
+	if err != nil {
+		return nil, err
+	}
+	if response.Statistics == nil {
+		return &discovery.Statistics{}, nil
+	}
+	return response.Statistics, nil
+}
+
+// GetAppServices returns the services registered in the application with the given details
+func (c *Client) GetAppServices(appID string, details []GovernOption, opts ...CallOption) ([]*discovery.ServiceDetail, error) {
+	if appID == "" {
+		return nil, errors.New("invalid app ID")
+	}
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	response, err := c.getServicesInfo("GetAppServices", []URLParameter{
+		{"appId": appID},
+		{"options": governOptions(details)},
+	}, copts)
+	if err != nil {
+		return nil, err
+	}
+	return response.AllServicesDetail, nil
+}
+
+// GetServicesGroupByApp returns all the services with the given details, grouped by application
+func (c *Client) GetServicesGroupByApp(details []GovernOption, opts ...CallOption) ([]*AppServices, error) {
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	response, err := c.getServicesInfo("GetServicesGroupByApp", []URLParameter{
+		{"options": governOptions(details)},
+	}, copts)
+	if err != nil {
+		return nil, err
+	}
+	var groups []*AppServices
+	index := make(map[string]*AppServices)
+	for _, detail := range response.AllServicesDetail {
+		if detail == nil || detail.MicroService == nil {
+			continue
+		}
+		appID := detail.MicroService.AppId
+		group, ok := index[appID]
+		if !ok {
+			group = &AppServices{AppID: appID}
+			index[appID] = group
+			groups = append(groups, group)
+		}
+		group.Services = append(group.Services, detail)
+	}
+	return groups, nil
+}
+
+// getServicesInfo queries the services overview of governance API, name is the API name used in error messages
+func (c *Client) getServicesInfo(name string, querys []URLParameter, copts *CallOptions) (*discovery.GetServicesInfoResponse, error) {
+	url := c.formatURL(GovernAPIPATH+MicroservicePath, querys, copts)
+	resp, err := c.httpDo("GET", url, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("%s failed, response is empty", name)
+	}
+	var body []byte
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewIOException(err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var response discovery.GetServicesInfoResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, NewJSONException(err, string(body))
+		}
+		return &response, nil
+	}
+	return nil, fmt.Errorf("%s failed, response StatusCode: %d, response body: %s", name, resp.StatusCode, string(body))
+}
@@ -0,0 +1,66 @@
+package sc_test
+
+import (
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestClient_Govern(t *testing.T) {
+	c, err := sc.NewClient(
+		sc.Options{
+			Endpoints: []string{"127.0.0.1:30100"},
+		})
+	assert.NoError(t, err)
+
+	sid, err := c.RegisterService(&discovery.MicroService{
+		ServiceName: "governService",
+		AppId:       "governApp",
+		Version:     "0.0.1",
+	})
+	if err != nil {
+		sid, err = c.GetMicroServiceID("governApp", "governService", "0.0.1", "")
+	}
+	assert.NoError(t, err)
+	assert.NotEmpty(t, sid)
+
+	t.Run("get service detail with options, should return the service", func(t *testing.T) {
+		detail, err := c.GetServiceDetail(sid, []sc.GovernOption{sc.GovernInstances, sc.GovernTags})
+		assert.NoError(t, err)
+		assert.Equal(t, "governService", detail.MicroService.ServiceName)
+	})
+	t.Run("get service detail with not exist service id, should return err", func(t *testing.T) {
+		_, err := c.GetServiceDetail("notExistServiceID", nil)
+		assert.Error(t, err)
+	})
+	t.Run("get statistics, should not be empty", func(t *testing.T) {
+		st, err := c.GetStatistics()
+		assert.NoError(t, err)
+		assert.NotNil(t, st.Services)
+		assert.NotZero(t, st.Services.Count)
+	})
+	t.Run("get app services, should contain the service", func(t *testing.T) {
+		services, err := c.GetAppServices("governApp", nil)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, services)
+		for _, s := range services {
+			assert.Equal(t, "governApp", s.MicroService.AppId)
+		}
+	})
+	t.Run("group services by app, should contain the app", func(t *testing.T) {
+		groups, err := c.GetServicesGroupByApp(nil)
+		assert.NoError(t, err)
+		found := false
+		for _, g := range groups {
+			if g.AppID == "governApp" {
+				found = true
+			}
+		}
+		assert.True(t, found)
+	})
+	_, err = c.UnregisterMicroService(sid)
+	assert.NoError(t, err)
+}
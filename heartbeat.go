@@ -0,0 +1,536 @@
+package sc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/go-chassis/cari/discovery"
+	"github.com/go-chassis/openlog"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultHeartbeatJitter is the default fraction of interval used to spread the heartbeat timers
+	DefaultHeartbeatJitter = 0.1
+	// DefaultHeartbeatBatchSize is the default max number of instances sent in one batch heartbeat
+	DefaultHeartbeatBatchSize = 100
+)
+
+// HeartbeatSchedulerOptions is the options of HeartbeatScheduler
+type HeartbeatSchedulerOptions struct {
+	// Interval is the heartbeat interval of each instance, default is DefaultLeaseRenewalInterval seconds
+	Interval time.Duration
+	// Jitter is the max fraction of Interval added to or subtracted from each timer, default is DefaultHeartbeatJitter
+	Jitter float64
+	// BatchSize is the max number of instances sent in one batch heartbeat, default is DefaultHeartbeatBatchSize
+	BatchSize int
+}
+
+type heartbeatEntry struct {
+	microServiceID         string
+	microServiceInstanceID string
+	// callback is used to re-register the instance, it returns the new instance id
+	callback    func() (string, error)
+	next        time.Time
+	lastSuccess time.Time
+	// reRegistering is true while the callback is running
+	reRegistering bool
+	// reRegistered is true if the callback ran after the last successful heartbeat
+	reRegistered bool
+}
+
+// HeartbeatScheduler keeps the leases of many instances registered by one process.
+// Each instance has its own jittered timer, the heartbeats due at the same time are sent
+// by the batch heartbeat API, if service-center does not support it, Heartbeat is used instead.
+type HeartbeatScheduler struct {
+	c       *Client
+	opt     HeartbeatSchedulerOptions
+	mutex   sync.Mutex
+	entries map[string]*heartbeatEntry
+	// batchUnsupported is only accessed by the scheduling goroutine
+	batchUnsupported bool
+	wakeCh           chan struct{}
+	stopCh           chan struct{}
+	startOnce        sync.Once
+	stopOnce         sync.Once
+}
+
+// NewHeartbeatScheduler creates a heartbeat scheduler, call Start to send heartbeats
+func (c *Client) NewHeartbeatScheduler(opt HeartbeatSchedulerOptions) *HeartbeatScheduler {
+	if opt.Interval <= 0 {
+		opt.Interval = DefaultLeaseRenewalInterval * time.Second
+	}
+	if opt.Jitter <= 0 || opt.Jitter >= 1 {
+		opt.Jitter = DefaultHeartbeatJitter
+	}
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = DefaultHeartbeatBatchSize
+	}
+	return &HeartbeatScheduler{
+		c:       c,
+		opt:     opt,
+		entries: make(map[string]*heartbeatEntry),
+		wakeCh:  make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Add schedules the heartbeat of an instance.
+// The callback function is used to re-register the instance when service-center reports it does not exist,
+// it returns the id of the re-registered instance, the heartbeat is sent for that id from then on.
+// The callback runs in its own goroutine, so a slow re-registration does not delay the heartbeats of the other instances.
+// If the instance still does not exist after the callback, or there is no callback, the instance is removed.
+func (s *HeartbeatScheduler) Add(microServiceID, microServiceInstanceID string, callback func() (string, error)) {
+	s.mutex.Lock()
+	s.entries[microServiceInstanceID] = &heartbeatEntry{
+		microServiceID:         microServiceID,
+		microServiceInstanceID: microServiceInstanceID,
+		callback:               callback,
+		next:                   time.Now().Add(s.jittered()),
+	}
+	s.mutex.Unlock()
+	s.wake()
+}
+
+// Remove stops sending the heartbeat of an instance
+func (s *HeartbeatScheduler) Remove(microServiceInstanceID string) {
+	s.mutex.Lock()
+	delete(s.entries, microServiceInstanceID)
+	s.mutex.Unlock()
+	s.wake()
+}
+
+// LastSuccess returns the time of the last successful heartbeat of an instance,
+// the time is zero if no heartbeat succeeded yet, ok is false if the instance is not scheduled
+func (s *HeartbeatScheduler) LastSuccess(microServiceInstanceID string) (t time.Time, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.entries[microServiceInstanceID]
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.lastSuccess, true
+}
+
+// LastSuccesses returns the time of the last successful heartbeat of all the scheduled instances
+func (s *HeartbeatScheduler) LastSuccesses() map[string]time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m := make(map[string]time.Time, len(s.entries))
+	for id, e := range s.entries {
+		m[id] = e.lastSuccess
+	}
+	return m
+}
+
+// Start starts sending heartbeats in a goroutine, it stops when Stop or Client.Close is called
+func (s *HeartbeatScheduler) Start() {
+	s.startOnce.Do(func() {
+		go s.run()
+	})
+}
+
+// Stop stops sending heartbeats
+func (s *HeartbeatScheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+func (s *HeartbeatScheduler) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// jittered returns the interval randomly changed by at most Jitter of it
+func (s *HeartbeatScheduler) jittered() time.Duration {
+	delta := (rand.Float64()*2 - 1) * s.opt.Jitter * float64(s.opt.Interval)
+	return s.opt.Interval + time.Duration(delta)
+}
+
+func (s *HeartbeatScheduler) run() {
+	for {
+		timer := time.NewTimer(s.untilNextDue())
+		select {
+		case <-s.stopCh:
+			timer.Stop()
+			return
+		case <-s.c.stopCh:
+			timer.Stop()
+			return
+		case <-s.wakeCh:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+		s.beat(s.dueEntries(time.Now()))
+	}
+}
+
+func (s *HeartbeatScheduler) untilNextDue() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	wait := s.opt.Interval
+	now := time.Now()
+	for _, e := range s.entries {
+		if d := e.next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// dueEntries returns the instances need to send heartbeat and schedules their next heartbeat
+func (s *HeartbeatScheduler) dueEntries(now time.Time) []heartbeatEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var due []heartbeatEntry
+	for _, e := range s.entries {
+		if e.next.After(now) {
+			continue
+		}
+		e.next = now.Add(s.jittered())
+		due = append(due, *e)
+	}
+	return due
+}
+
+func (s *HeartbeatScheduler) beat(due []heartbeatEntry) {
+	for len(due) > 0 && !s.batchUnsupported {
+		n := s.opt.BatchSize
+		if n > len(due) {
+			n = len(due)
+		}
+		if err := s.batchBeat(due[:n]); err != nil {
+			if err == ErrBatchHeartbeatNotSupported {
+				openlog.Warn("batch heartbeat is not supported by service-center, send heartbeat one by one")
+				s.batchUnsupported = true
+				break
+			}
+			openlog.Error(fmt.Sprintf("batch heartbeat failed: %s", err.Error()))
+		}
+		due = due[n:]
+	}
+	if !s.batchUnsupported {
+		return
+	}
+	for _, e := range due {
+		_, err := s.c.Heartbeat(e.microServiceID, e.microServiceInstanceID)
+		if err != nil {
+			s.failed(e, err, err == ErrMicroServiceInstanceNotExists)
+			continue
+		}
+		s.succeeded(e.microServiceInstanceID)
+	}
+}
+
+func (s *HeartbeatScheduler) batchBeat(entries []heartbeatEntry) error {
+	elements := make([]*discovery.HeartbeatSetElement, 0, len(entries))
+	for _, e := range entries {
+		elements = append(elements, &discovery.HeartbeatSetElement{
+			ServiceId:  e.microServiceID,
+			InstanceId: e.microServiceInstanceID,
+		})
+	}
+	results, err := s.c.BatchHeartbeat(elements)
+	if err != nil {
+		return err
+	}
+	failed := make(map[string]bool, len(results))
+	for _, r := range results {
+		if r != nil && r.ErrMessage != "" {
+			failed[r.InstanceId] = true
+		}
+	}
+	for _, e := range entries {
+		if !failed[e.microServiceInstanceID] {
+			s.succeeded(e.microServiceInstanceID)
+			continue
+		}
+		// the batch result carries no error code, retry the instance alone to know why it failed
+		if _, err := s.c.Heartbeat(e.microServiceID, e.microServiceInstanceID); err != nil {
+			s.failed(e, err, err == ErrMicroServiceInstanceNotExists)
+			continue
+		}
+		s.succeeded(e.microServiceInstanceID)
+	}
+	return nil
+}
+
+func (s *HeartbeatScheduler) succeeded(microServiceInstanceID string) {
+	s.mutex.Lock()
+	if e, ok := s.entries[microServiceInstanceID]; ok {
+		e.lastSuccess = time.Now()
+		e.reRegistered = false
+	}
+	s.mutex.Unlock()
+}
+
+func (s *HeartbeatScheduler) failed(due heartbeatEntry, err error, notExists bool) {
+	openlog.Error(fmt.Sprintf("heartbeat failed, MicroServiceId/MicroServiceInstanceId: %s/%s, error: %s",
+		due.microServiceID, due.microServiceInstanceID, err.Error()))
+	if !notExists {
+		return
+	}
+	s.mutex.Lock()
+	e, ok := s.entries[due.microServiceInstanceID]
+	if !ok || e.reRegistering {
+		s.mutex.Unlock()
+		return
+	}
+	if e.callback == nil || e.reRegistered {
+		// the instance is gone for good, stop sending heartbeats for it
+		delete(s.entries, due.microServiceInstanceID)
+		s.mutex.Unlock()
+		openlog.Warn(fmt.Sprintf("instance %s does not exist after re-registration, its heartbeat is removed",
+			due.microServiceInstanceID))
+		return
+	}
+	// If the instance does not exist, it should be re-registered
+	e.reRegistering = true
+	s.mutex.Unlock()
+	go func() {
+		id, err := e.callback()
+		s.mutex.Lock()
+		e.reRegistering = false
+		if err != nil {
+			// try again when the next heartbeat fails
+			s.mutex.Unlock()
+			openlog.Error(fmt.Sprintf("failed to re-register instance %s: %s", due.microServiceInstanceID, err.Error()))
+			return
+		}
+		e.reRegistered = true
+		if id != "" && id != e.microServiceInstanceID && s.entries[e.microServiceInstanceID] == e {
+			delete(s.entries, e.microServiceInstanceID)
+			e.microServiceInstanceID = id
+			e.next = time.Now()
+			s.entries[id] = e
+		}
+		s.mutex.Unlock()
+		s.wake()
+	}()
+}
+
+// HeartbeatState is the state of the heartbeat started by WSHeartbeat
+type HeartbeatState string
+
+const (
+	// HeartbeatConnected means the websocket connection is established
+	HeartbeatConnected HeartbeatState = "CONNECTED"
+	// HeartbeatReconnecting means the websocket connection is broken and being re-established
+	HeartbeatReconnecting HeartbeatState = "RECONNECTING"
+	// HeartbeatHTTP means service-center refused the websocket upgrade, Heartbeat is sent over http
+	HeartbeatHTTP HeartbeatState = "HTTP"
+	// HeartbeatStopped means the heartbeat is stopped
+	HeartbeatStopped HeartbeatState = "STOPPED"
+)
+
+// DefaultWSWriteTimeout is the timeout of writing a websocket ping message
+const DefaultWSWriteTimeout = 10 * time.Second
+
+// HeartbeatHandle controls the heartbeat started by WSHeartbeat
+type HeartbeatHandle struct {
+	c                      *Client
+	microServiceID         string
+	microServiceInstanceID string
+	callback               func()
+	mutex                  sync.Mutex
+	state                  HeartbeatState
+	conn                   *websocket.Conn
+	ctx                    context.Context
+	cancel                 context.CancelFunc
+	doneCh                 chan struct{}
+}
+
+func newHeartbeatHandle(c *Client, microServiceID, microServiceInstanceID string, callback func()) *HeartbeatHandle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HeartbeatHandle{
+		c:                      c,
+		microServiceID:         microServiceID,
+		microServiceInstanceID: microServiceInstanceID,
+		callback:               callback,
+		state:                  HeartbeatConnected,
+		ctx:                    ctx,
+		cancel:                 cancel,
+		doneCh:                 make(chan struct{}),
+	}
+}
+
+// Stop stops the heartbeat and closes the websocket connection, it does not unregister the instance
+func (h *HeartbeatHandle) Stop() {
+	h.cancel()
+	h.mutex.Lock()
+	conn := h.conn
+	h.mutex.Unlock()
+	h.closeConn(conn)
+}
+
+// State returns the current state of the heartbeat
+func (h *HeartbeatHandle) State() HeartbeatState {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.state
+}
+
+// Done returns a channel which is closed after the heartbeat is stopped
+func (h *HeartbeatHandle) Done() <-chan struct{} {
+	return h.doneCh
+}
+
+func (h *HeartbeatHandle) setState(state HeartbeatState) {
+	h.mutex.Lock()
+	h.state = state
+	h.mutex.Unlock()
+}
+
+// setConn records the current connection, it returns false if the heartbeat is already stopped
+func (h *HeartbeatHandle) setConn(conn *websocket.Conn) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.ctx.Err() != nil {
+		return false
+	}
+	h.conn = conn
+	return true
+}
+
+func (h *HeartbeatHandle) closeConn(conn *websocket.Conn) {
+	if conn == nil {
+		return
+	}
+	if err := conn.Close(); err != nil {
+		openlog.Debug(fmt.Sprintf("failed to close websocket connection %s", err.Error()))
+	}
+	h.c.mutex.Lock()
+	if h.c.conns[h.microServiceInstanceID] == conn {
+		delete(h.c.conns, h.microServiceInstanceID)
+	}
+	h.c.mutex.Unlock()
+}
+
+func (h *HeartbeatHandle) interval() time.Duration {
+	if interval := h.c.options().HeartbeatInterval; interval > 0 {
+		return interval
+	}
+	return DefaultLeaseRenewalInterval * time.Second
+}
+
+func (h *HeartbeatHandle) reRegister() {
+	if h.callback != nil {
+		h.callback()
+	}
+}
+
+// run keeps the heartbeat until it is stopped, a nil conn means the websocket upgrade was refused
+func (h *HeartbeatHandle) run(conn *websocket.Conn) {
+	defer close(h.doneCh)
+	defer h.setState(HeartbeatStopped)
+	go func() {
+		select {
+		case <-h.c.stopCh:
+			h.Stop()
+		case <-h.ctx.Done():
+		}
+	}()
+	for conn != nil {
+		if !h.setConn(conn) {
+			h.closeConn(conn)
+			return
+		}
+		h.setState(HeartbeatConnected)
+		err := h.serve(conn)
+		h.closeConn(conn)
+		if h.ctx.Err() != nil {
+			return
+		}
+		openlog.Error(err.Error())
+		if websocket.IsCloseError(err, discovery.ErrWebsocketInstanceNotExists) {
+			// If the instance does not exist, it is closed normally and should be re-registered
+			h.reRegister()
+		}
+		h.setState(HeartbeatReconnecting)
+		conn, err = h.reconnect()
+		if err != nil && err != errWebsocketUpgradeRefused {
+			// the heartbeat is stopped while reconnecting
+			return
+		}
+	}
+	h.httpHeartbeat()
+}
+
+// serve reads the connection until it is broken, and sends ping messages meanwhile
+func (h *HeartbeatHandle) serve(conn *websocket.Conn) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(h.interval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(DefaultWSWriteTimeout))
+			if err != nil {
+				openlog.Warn(fmt.Sprintf("%s's websocket ping failed: %s", h.microServiceInstanceID, err.Error()))
+			}
+		}
+	}()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *HeartbeatHandle) reconnect() (*websocket.Conn, error) {
+	var conn *websocket.Conn
+	operation := func() error {
+		var err error
+		conn, err = h.c.setupWSConnection(h.microServiceID, h.microServiceInstanceID)
+		if err == errWebsocketUpgradeRefused {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	boff := backoff.NewExponentialBackOff()
+	boff.MaxElapsedTime = 0
+	err := backoff.RetryNotify(operation, backoff.WithContext(boff, h.ctx),
+		func(err error, duration time.Duration) {
+			openlog.Error(fmt.Sprintf("failed err: %s,and it will be executed again in %v", err.Error(), duration))
+		})
+	return conn, err
+}
+
+// httpHeartbeat sends Heartbeat over http until the heartbeat is stopped
+func (h *HeartbeatHandle) httpHeartbeat() {
+	openlog.Warn(fmt.Sprintf("websocket upgrade is refused, %s's heartbeat falls back to http", h.microServiceInstanceID))
+	h.setState(HeartbeatHTTP)
+	ticker := time.NewTicker(h.interval())
+	defer ticker.Stop()
+	for {
+		_, err := h.c.Heartbeat(h.microServiceID, h.microServiceInstanceID)
+		if err != nil {
+			openlog.Error(fmt.Sprintf("heartbeat failed, MicroServiceId/MicroServiceInstanceId: %s/%s, error: %s",
+				h.microServiceID, h.microServiceInstanceID, err.Error()))
+			if err == ErrMicroServiceInstanceNotExists {
+				h.reRegister()
+			}
+		}
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestHeartbeatScheduler(t *testing.T) {
+	var mutex sync.Mutex
+	// the instances not existing in service center
+	missing := map[string]bool{"gone": true, "revived": true}
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if strings.HasSuffix(request.URL.Path, "/heartbeat") {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if missing[path.Base(path.Dir(request.URL.Path))] {
+				writer.WriteHeader(http.StatusBadRequest)
+				writer.Write([]byte(`{"errorCode":"400017","errorMessage":"Instance does not exist."}`))
+			}
+			return
+		}
+		if request.URL.Path != "/v4/default/registry/heartbeats" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := ioutil.ReadAll(request.Body)
+		var req discovery.HeartbeatSetRequest
+		json.Unmarshal(body, &req)
+		resp := &discovery.HeartbeatSetResponse{}
+		status := http.StatusOK
+		mutex.Lock()
+		for _, i := range req.Instances {
+			r := &discovery.InstanceHbRst{ServiceId: i.ServiceId, InstanceId: i.InstanceId}
+			if missing[i.InstanceId] {
+				r.ErrMessage = "Instance does not exist."
+				status = http.StatusBadRequest
+			}
+			resp.Instances = append(resp.Instances, r)
+		}
+		mutex.Unlock()
+		b, _ := json.Marshal(resp)
+		writer.WriteHeader(status)
+		writer.Write(b)
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	s := c.NewHeartbeatScheduler(sc.HeartbeatSchedulerOptions{Interval: 100 * time.Millisecond})
+	var reRegistered int32
+	s.Add("sid", "alive", func() (string, error) {
+		t.Error("alive instance should not be re-registered")
+		return "alive", nil
+	})
+	s.Add("sid", "gone", func() (string, error) {
+		// a slow re-registration which does not bring the instance back
+		atomic.AddInt32(&reRegistered, 1)
+		time.Sleep(400 * time.Millisecond)
+		return "gone", nil
+	})
+	s.Add("sid", "revived", func() (string, error) {
+		// registered again with a new id
+		return "renewed", nil
+	})
+	s.Start()
+	defer s.Stop()
+	time.Sleep(350 * time.Millisecond)
+
+	// the slow callback does not delay the other heartbeats
+	last, ok := s.LastSuccess("alive")
+	assert.True(t, ok)
+	assert.True(t, time.Since(last) < 200*time.Millisecond)
+	last, ok = s.LastSuccess("gone")
+	assert.True(t, ok)
+	assert.True(t, last.IsZero())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reRegistered))
+
+	time.Sleep(500 * time.Millisecond)
+	// still missing after re-registration, the heartbeat is removed
+	_, ok = s.LastSuccess("gone")
+	assert.False(t, ok)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reRegistered))
+	_, ok = s.LastSuccess("revived")
+	assert.False(t, ok)
+	last, ok = s.LastSuccess("renewed")
+	assert.True(t, ok)
+	assert.False(t, last.IsZero())
+
+	s.Remove("alive")
+	_, ok = s.LastSuccess("alive")
+	assert.False(t, ok)
+	assert.Len(t, s.LastSuccesses(), 1)
+}
+
+func TestClient_WSHeartbeat_FallbackToHTTP(t *testing.T) {
+	var heartbeats int32
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodPut {
+			atomic.AddInt32(&heartbeats, 1)
+			writer.WriteHeader(http.StatusOK)
+			return
+		}
+		// refuse the websocket upgrade
+		writer.WriteHeader(http.StatusNotFound)
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:         []string{scServer.Listener.Addr().String()},
+		HeartbeatInterval: 100 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	h, err := c.WSHeartbeat("sid", "iid", nil)
+	assert.NoError(t, err)
+	time.Sleep(350 * time.Millisecond)
+	assert.Equal(t, sc.HeartbeatHTTP, h.State())
+	assert.True(t, atomic.LoadInt32(&heartbeats) >= 2)
+
+	assert.NoError(t, c.Close())
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat should be stopped after the client is closed")
+	}
+	assert.Equal(t, sc.HeartbeatStopped, h.State())
+}
+
+func TestClient_WSHeartbeat_RetryHandshakeErrors(t *testing.T) {
+	var dials int32
+	upgrader := websocket.Upgrader{}
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch atomic.AddInt32(&dials, 1) {
+		case 2:
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		case 3:
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(writer, request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if atomic.LoadInt32(&dials) == 1 {
+			// break the first connection
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	h, err := c.WSHeartbeat("sid", "iid", nil)
+	assert.NoError(t, err)
+	time.Sleep(3 * time.Second)
+	// 401 and 503 are retried instead of falling back to http
+	assert.Equal(t, int32(4), atomic.LoadInt32(&dials))
+	assert.Equal(t, sc.HeartbeatConnected, h.State())
+	h.Stop()
+}
+
+func TestClient_WSHeartbeat_Stop(t *testing.T) {
+	var reRegistered int32
+	upgrader := websocket.Upgrader{}
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		conn, err := upgrader.Upgrade(writer, request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if atomic.LoadInt32(&reRegistered) == 0 {
+			conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(discovery.ErrWebsocketInstanceNotExists, "instance does not exist"))
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	h, err := c.WSHeartbeat("sid", "iid", func() {
+		atomic.AddInt32(&reRegistered, 1)
+	})
+	assert.NoError(t, err)
+	time.Sleep(2 * time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reRegistered))
+	assert.Equal(t, sc.HeartbeatConnected, h.State())
+
+	h.Stop()
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat should be stopped")
+	}
+	assert.Equal(t, sc.HeartbeatStopped, h.State())
+}
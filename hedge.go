@@ -0,0 +1,228 @@
+package sc
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultHedgingDelay is the default time waited for a response before the hedged request is sent
+	DefaultHedgingDelay = 50 * time.Millisecond
+	// DefaultHedgingMaxInFlight is the default number of hedged requests in flight of a client
+	DefaultHedgingMaxInFlight = 10
+)
+
+// HedgingOptions is the options of request hedging.
+// The read-only discovery calls send a second request to another address of the pool
+// if the first one does not respond in Delay, and take the first successful response.
+type HedgingOptions struct {
+	// Delay is the time waited for a response before the hedged request is sent, default is DefaultHedgingDelay
+	Delay time.Duration
+	// MaxInFlight caps the hedged requests in flight, the calls beyond it are not hedged,
+	// default is DefaultHedgingMaxInFlight
+	MaxInFlight int
+}
+
+// HedgingStats is the counters of request hedging
+type HedgingStats struct {
+	// Hedged is the number of hedged requests sent
+	Hedged uint64
+	// Wins is the number of hedged requests whose response was taken
+	Wins uint64
+	// Throttled is the number of hedged requests not sent because MaxInFlight was reached
+	Throttled uint64
+}
+
+type hedger struct {
+	opt       HedgingOptions
+	inFlight  chan struct{}
+	hedged    uint64
+	wins      uint64
+	throttled uint64
+}
+
+func newHedger(opt HedgingOptions) *hedger {
+	if opt.Delay <= 0 {
+		opt.Delay = DefaultHedgingDelay
+	}
+	if opt.MaxInFlight <= 0 {
+		opt.MaxInFlight = DefaultHedgingMaxInFlight
+	}
+	return &hedger{
+		opt:      opt,
+		inFlight: make(chan struct{}, opt.MaxInFlight),
+	}
+}
+
+// HedgingStats returns the counters of request hedging, they are zero if Options.Hedging is not set
+func (c *Client) HedgingStats() HedgingStats {
+	if c.hedger == nil {
+		return HedgingStats{}
+	}
+	return HedgingStats{
+		Hedged:    atomic.LoadUint64(&c.hedger.hedged),
+		Wins:      atomic.LoadUint64(&c.hedger.wins),
+		Throttled: atomic.LoadUint64(&c.hedger.throttled),
+	}
+}
+
+// hedgedDo makes a read-only request, it is hedged if hedging is enabled and the address is not specified by the call
+func (c *Client) hedgedDo(method string, rawURL string, headers http.Header, body []byte, copts *CallOptions) (*http.Response, error) {
+	if c.hedger == nil || (copts != nil && copts.Address != "") {
+		return c.httpDo(method, rawURL, headers, body)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return c.httpDo(method, rawURL, headers, body)
+	}
+	alternative := c.hedgeAddress(u.Host)
+	if alternative == "" {
+		return c.httpDo(method, rawURL, headers, body)
+	}
+	hedgedURL := *u
+	hedgedURL.Host = alternative
+	return c.hedger.do(c, method, rawURL, hedgedURL.String(), headers, body)
+}
+
+// hedgeAddress returns an address of the pool other than host, empty if there is none
+func (c *Client) hedgeAddress(host string) string {
+	c.poolMutex.Lock()
+	endpoints := c.syncedEndpoints
+	if endpoints == nil {
+		endpoints = c.activeEndpoints
+	}
+	c.poolMutex.Unlock()
+	if endpoints == nil {
+		endpoints = c.options().Endpoints
+	}
+	candidates := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep != host {
+			candidates = append(candidates, ep)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[rand.Intn(len(candidates))] // #nosec G404
+}
+
+type hedgeResult struct {
+	index  int
+	resp   *http.Response
+	err    error
+	hedged bool
+	cancel context.CancelFunc
+}
+
+func (r *hedgeResult) succeeded() bool {
+	return r.err == nil && r.resp != nil && r.resp.StatusCode < http.StatusInternalServerError
+}
+
+func (r *hedgeResult) discard() {
+	if r.resp != nil {
+		r.resp.Body.Close()
+	}
+	r.cancel()
+}
+
+// do sends the request to rawURL, and to hedgedURL if the first one does not succeed in time,
+// a failed first request triggers the hedged one immediately
+func (h *hedger) do(c *Client, method, rawURL, hedgedURL string, headers http.Header, body []byte) (*http.Response, error) {
+	results := make(chan *hedgeResult, 2)
+	var cancels []context.CancelFunc
+	send := func(target string, hedged bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		// httpDo adds the default headers to the map, so each request has its own copy
+		hs := headers.Clone()
+		go func() {
+			resp, err := c.httpDoContext(ctx, method, target, hs, body)
+			if hedged {
+				<-h.inFlight
+			}
+			results <- &hedgeResult{index: index, resp: resp, err: err, hedged: hedged, cancel: cancel}
+		}()
+	}
+	hedged := false
+	pending := 0
+	hedge := func() {
+		if hedged {
+			return
+		}
+		hedged = true
+		select {
+		case h.inFlight <- struct{}{}:
+			atomic.AddUint64(&h.hedged, 1)
+			send(hedgedURL, true)
+			pending++
+		default:
+			atomic.AddUint64(&h.throttled, 1)
+		}
+	}
+
+	send(rawURL, false)
+	pending++
+	timer := time.NewTimer(h.opt.Delay)
+	defer timer.Stop()
+	var last *hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			hedge()
+		case r := <-results:
+			pending--
+			if r.succeeded() {
+				if r.hedged {
+					atomic.AddUint64(&h.wins, 1)
+				}
+				if last != nil {
+					last.discard()
+				}
+				for i, cancel := range cancels {
+					if i != r.index {
+						cancel()
+					}
+				}
+				go drainHedgeResults(results, pending)
+				r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: r.cancel}
+				return r.resp, nil
+			}
+			if last != nil {
+				last.discard()
+			}
+			last = r
+			hedge()
+		}
+	}
+	if last.resp != nil {
+		last.resp.Body = &cancelOnClose{ReadCloser: last.resp.Body, cancel: last.cancel}
+	} else {
+		last.cancel()
+	}
+	return last.resp, last.err
+}
+
+func drainHedgeResults(results chan *hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		(<-results).discard()
+	}
+}
+
+// cancelOnClose releases the context of a request after its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
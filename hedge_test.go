@@ -0,0 +1,60 @@
+package sc_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestClient_Hedging(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(time.Second)
+		writer.Write([]byte(`{"instances":[{"instanceId":"slow"}]}`))
+	}))
+	defer slow.Close()
+	fast := newInstancesServer(&discovery.MicroServiceInstance{InstanceId: "fast"})
+	defer fast.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{slow.Listener.Addr().String(), fast.Listener.Addr().String()},
+		Hedging:   &sc.HedgingOptions{Delay: 20 * time.Millisecond, MaxInFlight: 1},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	start := time.Now()
+	instances, err := c.GetMicroServiceInstances("consumer", "provider")
+	assert.NoError(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.Equal(t, "fast", instances[0].InstanceId)
+	assert.Equal(t, sc.HedgingStats{Hedged: 1, Wins: 1}, c.HedgingStats())
+
+	t.Run("the call with address is not hedged", func(t *testing.T) {
+		instances, err := c.GetMicroServiceInstances("consumer", "provider", sc.WithAddress(slow.Listener.Addr().String()))
+		assert.NoError(t, err)
+		assert.Equal(t, "slow", instances[0].InstanceId)
+		assert.Equal(t, uint64(1), c.HedgingStats().Hedged)
+	})
+	t.Run("a failed request is hedged immediately", func(t *testing.T) {
+		broken := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer broken.Close()
+		c, err := sc.NewClient(sc.Options{
+			Endpoints: []string{broken.Listener.Addr().String(), fast.Listener.Addr().String()},
+			Hedging:   &sc.HedgingOptions{Delay: time.Minute},
+		})
+		assert.NoError(t, err)
+		defer c.Close()
+		instances, err := c.GetMicroServiceInstances("consumer", "provider")
+		assert.NoError(t, err)
+		assert.Equal(t, "fast", instances[0].InstanceId)
+		assert.Equal(t, uint64(1), c.HedgingStats().Wins)
+	})
+}
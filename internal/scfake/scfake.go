@@ -0,0 +1,369 @@
+// Package scfake is an in-memory service center serving the registry and governance APIs used by the tests
+package scfake
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+const (
+	registryPath = "/v4/default/registry"
+	governPath   = "/v4/default/govern"
+)
+
+// Service is the state of a service in the fake
+type Service struct {
+	MicroService *discovery.MicroService
+	Schemas      map[string]*discovery.Schema
+	Tags         map[string]string
+	Rules        []*discovery.ServiceRule
+	Providers    []*discovery.MicroServiceKey
+	Instances    []*discovery.MicroServiceInstance
+}
+
+// Server is a fake service center, the state can be read and written under Lock
+type Server struct {
+	*httptest.Server
+	sync.Mutex
+	Services map[string]*Service
+	// Requests records the method and path of the requests changing the state, like "POST /tags"
+	Requests []string
+	seq      int
+}
+
+// New starts a fake service center
+func New() *Server {
+	s := &Server{Services: make(map[string]*Service)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Addr returns the address to be used as the endpoint of client
+func (s *Server) Addr() string {
+	return s.Listener.Addr().String()
+}
+
+// AddService adds the service to the fake, it returns the service id
+func (s *Server) AddService(ms *discovery.MicroService) string {
+	s.Lock()
+	defer s.Unlock()
+	return s.addService(ms).MicroService.ServiceId
+}
+
+func (s *Server) addService(ms *discovery.MicroService) *Service {
+	copied := *ms
+	if copied.ServiceId == "" {
+		s.seq++
+		copied.ServiceId = fmt.Sprintf("s%d", s.seq)
+	}
+	service := &Service{
+		MicroService: &copied,
+		Schemas:      make(map[string]*discovery.Schema),
+		Tags:         make(map[string]string),
+	}
+	s.Services[copied.ServiceId] = service
+	return service
+}
+
+// Find returns the service by key, nil if it does not exist
+func (s *Server) Find(appID, name, version, env string) *Service {
+	for _, service := range s.Services {
+		ms := service.MicroService
+		if ms.AppId == appID && ms.ServiceName == name && ms.Version == version && ms.Environment == env {
+			return service
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, _ := json.Marshal(v)
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, code string) {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(fmt.Sprintf(`{"errorCode":"%s","errorMessage":"error %s"}`, code, code)))
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	path := r.URL.Path
+	if r.Method != http.MethodGet {
+		s.Requests = append(s.Requests, r.Method+" "+path)
+	}
+	switch {
+	case strings.HasPrefix(path, governPath+"/microservices"):
+		s.serveGovern(w, r, strings.TrimPrefix(path, governPath+"/microservices"))
+	case path == registryPath+"/existence":
+		q := r.URL.Query()
+		service := s.Find(q.Get("appId"), q.Get("serviceName"), q.Get("version"), q.Get("env"))
+		if service == nil {
+			writeError(w, "400012")
+			return
+		}
+		writeJSON(w, &discovery.GetExistenceResponse{ServiceId: service.MicroService.ServiceId})
+	case path == registryPath+"/dependencies":
+		var request discovery.AddDependenciesRequest
+		json.Unmarshal(body, &request)
+		for _, dep := range request.Dependencies {
+			consumer := s.Find(dep.Consumer.AppId, dep.Consumer.ServiceName, dep.Consumer.Version, dep.Consumer.Environment)
+			if consumer == nil {
+				writeError(w, "400012")
+				return
+			}
+			if r.Method == http.MethodPut {
+				consumer.Providers = nil
+			}
+			consumer.Providers = append(consumer.Providers, dep.Providers...)
+		}
+	case path == registryPath+"/microservices":
+		s.serveServices(w, r, body)
+	case strings.HasPrefix(path, registryPath+"/microservices/"):
+		parts := strings.Split(strings.TrimPrefix(path, registryPath+"/microservices/"), "/")
+		service, ok := s.Services[parts[0]]
+		if !ok {
+			writeError(w, "400012")
+			return
+		}
+		s.serveService(w, r, service, parts[1:], body)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) serveServices(w http.ResponseWriter, r *http.Request, body []byte) {
+	if r.Method == http.MethodGet {
+		response := &discovery.GetServicesResponse{}
+		for _, id := range s.sortedIDs() {
+			response.Services = append(response.Services, s.Services[id].MicroService)
+		}
+		writeJSON(w, response)
+		return
+	}
+	var request discovery.CreateServiceRequest
+	json.Unmarshal(body, &request)
+	ms := request.Service
+	if s.Find(ms.AppId, ms.ServiceName, ms.Version, ms.Environment) != nil {
+		writeError(w, "400010")
+		return
+	}
+	writeJSON(w, &discovery.GetExistenceResponse{ServiceId: s.addService(ms).MicroService.ServiceId})
+}
+
+func (s *Server) serveService(w http.ResponseWriter, r *http.Request, service *Service, parts []string, body []byte) {
+	id := service.MicroService.ServiceId
+	sub, name := "", ""
+	if len(parts) > 0 {
+		sub = parts[0]
+	}
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		writeJSON(w, &discovery.GetServiceResponse{Service: service.MicroService})
+	case sub == "" && r.Method == http.MethodDelete:
+		delete(s.Services, id)
+	case sub == "properties":
+		var ms discovery.MicroService
+		json.Unmarshal(body, &ms)
+		service.MicroService.Properties = ms.Properties
+		s.seq++
+		service.MicroService.ModTimestamp = fmt.Sprint(s.seq)
+	case sub == "schemas" && name == "" && r.Method == http.MethodGet:
+		response := &discovery.GetAllSchemaResponse{}
+		for _, schemaID := range sortedKeys(service.Schemas) {
+			schema := *service.Schemas[schemaID]
+			if r.URL.Query().Get("withSchema") != "1" {
+				schema.Schema = ""
+			}
+			response.Schemas = append(response.Schemas, &schema)
+		}
+		writeJSON(w, response)
+	case sub == "schemas" && r.Method == http.MethodGet:
+		schema, ok := service.Schemas[name]
+		if !ok {
+			writeError(w, "400016")
+			return
+		}
+		writeJSON(w, &discovery.GetSchemaResponse{Schema: schema.Schema, SchemaSummary: schema.Summary})
+	case sub == "schemas" && name == "" && r.Method == http.MethodPost:
+		var request discovery.ModifySchemasRequest
+		json.Unmarshal(body, &request)
+		service.Schemas = make(map[string]*discovery.Schema)
+		service.MicroService.Schemas = nil
+		for _, schema := range request.Schemas {
+			service.Schemas[schema.SchemaId] = schema
+			service.MicroService.Schemas = append(service.MicroService.Schemas, schema.SchemaId)
+		}
+	case sub == "schemas" && r.Method == http.MethodPut:
+		var request discovery.ModifySchemaRequest
+		json.Unmarshal(body, &request)
+		summary := request.Summary
+		if summary == "" {
+			summary = fmt.Sprintf("%x", sha256.Sum256([]byte(request.Schema)))
+		}
+		if _, ok := service.Schemas[name]; !ok {
+			service.MicroService.Schemas = append(service.MicroService.Schemas, name)
+		}
+		service.Schemas[name] = &discovery.Schema{SchemaId: name, Schema: request.Schema, Summary: summary}
+	case sub == "schemas" && r.Method == http.MethodDelete:
+		if _, ok := service.Schemas[name]; !ok {
+			writeError(w, "400016")
+			return
+		}
+		delete(service.Schemas, name)
+	case sub == "tags" && r.Method == http.MethodPost:
+		var request discovery.AddServiceTagsRequest
+		json.Unmarshal(body, &request)
+		for k, v := range request.Tags {
+			service.Tags[k] = v
+		}
+	case sub == "tags" && r.Method == http.MethodDelete:
+		for _, k := range strings.Split(name, ",") {
+			delete(service.Tags, k)
+		}
+	case sub == "rules" && r.Method == http.MethodPost:
+		var request discovery.AddServiceRulesRequest
+		json.Unmarshal(body, &request)
+		for _, rule := range request.Rules {
+			s.seq++
+			service.Rules = append(service.Rules, &discovery.ServiceRule{
+				RuleId:      fmt.Sprintf("r%d", s.seq),
+				RuleType:    rule.RuleType,
+				Attribute:   rule.Attribute,
+				Pattern:     rule.Pattern,
+				Description: rule.Description,
+			})
+		}
+	case sub == "rules" && r.Method == http.MethodPut:
+		var request discovery.AddOrUpdateServiceRule
+		json.Unmarshal(body, &request)
+		for _, rule := range service.Rules {
+			if rule.RuleId == name {
+				rule.RuleType, rule.Attribute = request.RuleType, request.Attribute
+				rule.Pattern, rule.Description = request.Pattern, request.Description
+				return
+			}
+		}
+		writeError(w, "400019")
+	case sub == "rules" && r.Method == http.MethodDelete:
+		deleted := make(map[string]bool)
+		for _, ruleID := range strings.Split(name, ",") {
+			deleted[ruleID] = true
+		}
+		kept := service.Rules[:0]
+		for _, rule := range service.Rules {
+			if !deleted[rule.RuleId] {
+				kept = append(kept, rule)
+			}
+		}
+		service.Rules = kept
+	case sub == "instances" && r.Method == http.MethodGet:
+		writeJSON(w, &discovery.GetInstancesResponse{Instances: service.Instances})
+	case sub == "instances" && r.Method == http.MethodPost:
+		var request discovery.RegisterInstanceRequest
+		json.Unmarshal(body, &request)
+		instance := request.Instance
+		if instance.InstanceId == "" {
+			s.seq++
+			instance.InstanceId = fmt.Sprintf("i%d", s.seq)
+		}
+		// registering with an existing instance id updates the instance
+		kept := service.Instances[:0]
+		for _, existing := range service.Instances {
+			if existing.InstanceId != instance.InstanceId {
+				kept = append(kept, existing)
+			}
+		}
+		service.Instances = append(kept, instance)
+		writeJSON(w, &discovery.RegisterInstanceResponse{InstanceId: instance.InstanceId})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) serveGovern(w http.ResponseWriter, r *http.Request, path string) {
+	options := r.URL.Query().Get("options")
+	if path == "" {
+		response := &discovery.GetServicesInfoResponse{}
+		for _, id := range s.sortedIDs() {
+			response.AllServicesDetail = append(response.AllServicesDetail, s.detail(s.Services[id], options))
+		}
+		writeJSON(w, response)
+		return
+	}
+	service, ok := s.Services[strings.TrimPrefix(path, "/")]
+	if !ok {
+		writeError(w, "400012")
+		return
+	}
+	writeJSON(w, &discovery.GetServiceDetailResponse{Service: s.detail(service, options)})
+}
+
+func (s *Server) detail(service *Service, options string) *discovery.ServiceDetail {
+	with := func(option string) bool {
+		return options == "all" || strings.Contains(","+options+",", ","+option+",")
+	}
+	detail := &discovery.ServiceDetail{MicroService: service.MicroService}
+	if with("schemas") {
+		for _, schemaID := range sortedKeys(service.Schemas) {
+			detail.SchemaInfos = append(detail.SchemaInfos, service.Schemas[schemaID])
+		}
+	}
+	if with("tags") && len(service.Tags) != 0 {
+		detail.Tags = service.Tags
+	}
+	if with("rules") {
+		detail.Rules = service.Rules
+	}
+	if with("instances") {
+		detail.Instances = service.Instances
+	}
+	if with("dependencies") {
+		for _, key := range service.Providers {
+			if provider := s.Find(key.AppId, key.ServiceName, key.Version, key.Environment); provider != nil {
+				detail.Providers = append(detail.Providers, provider.MicroService)
+			}
+		}
+		for _, consumer := range s.Services {
+			for _, key := range consumer.Providers {
+				ms := service.MicroService
+				if key.AppId == ms.AppId && key.ServiceName == ms.ServiceName && key.Version == ms.Version && key.Environment == ms.Environment {
+					detail.Consumers = append(detail.Consumers, consumer.MicroService)
+				}
+			}
+		}
+	}
+	return detail
+}
+
+func (s *Server) sortedIDs() []string {
+	ids := make([]string, 0, len(s.Services))
+	for id := range s.Services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedKeys(m map[string]*discovery.Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
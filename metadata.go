@@ -0,0 +1,114 @@
+package sc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+// the paths of the tags and rules of a service
+const (
+	TagsPath  = "/tags"
+	RulesPath = "/rules"
+)
+
+// AddTags adds the tags to the service, the existing tags with the same keys are overwritten
+func (c *Client) AddTags(microServiceID string, tags map[string]string) error {
+	if microServiceID == "" || len(tags) == 0 {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s", MSAPIPath, MicroservicePath, microServiceID, TagsPath), nil, nil)
+	return c.modify("AddTags", "POST", url, &discovery.AddServiceTagsRequest{Tags: tags})
+}
+
+// DeleteTags deletes the tags of the service by keys
+func (c *Client) DeleteTags(microServiceID string, keys []string) error {
+	if microServiceID == "" || len(keys) == 0 {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s/%s", MSAPIPath, MicroservicePath, microServiceID, TagsPath,
+		strings.Join(keys, ",")), nil, nil)
+	return c.modify("DeleteTags", "DELETE", url, nil)
+}
+
+// AddRules adds the black or white list rules to the service
+func (c *Client) AddRules(microServiceID string, rules []*discovery.AddOrUpdateServiceRule) error {
+	if microServiceID == "" || len(rules) == 0 {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s", MSAPIPath, MicroservicePath, microServiceID, RulesPath), nil, nil)
+	return c.modify("AddRules", "POST", url, &discovery.AddServiceRulesRequest{Rules: rules})
+}
+
+// UpdateRule replaces the rule of the service by rule id
+func (c *Client) UpdateRule(microServiceID, ruleID string, rule *discovery.AddOrUpdateServiceRule) error {
+	if microServiceID == "" || ruleID == "" || rule == nil {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s/%s", MSAPIPath, MicroservicePath, microServiceID, RulesPath, ruleID), nil, nil)
+	return c.modify("UpdateRule", "PUT", url, rule)
+}
+
+// DeleteRules deletes the rules of the service by rule ids
+func (c *Client) DeleteRules(microServiceID string, ruleIDs []string) error {
+	if microServiceID == "" || len(ruleIDs) == 0 {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s/%s", MSAPIPath, MicroservicePath, microServiceID, RulesPath,
+		strings.Join(ruleIDs, ",")), nil, nil)
+	return c.modify("DeleteRules", "DELETE", url, nil)
+}
+
+// AddDependencies adds the providers to the dependencies of the consumer
+func (c *Client) AddDependencies(consumer *discovery.MicroServiceKey, providers []*discovery.MicroServiceKey) error {
+	if consumer == nil || len(providers) == 0 {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(MSAPIPath+DependencyPath, nil, nil)
+	return c.modify("AddDependencies", "POST", url, &discovery.AddDependenciesRequest{
+		Dependencies: []*discovery.ConsumerDependency{{Consumer: consumer, Providers: providers}},
+	})
+}
+
+// SetDependencies replaces the dependencies of the consumer with the providers
+func (c *Client) SetDependencies(consumer *discovery.MicroServiceKey, providers []*discovery.MicroServiceKey) error {
+	if consumer == nil {
+		return errors.New("invalid request parameter")
+	}
+	url := c.formatURL(MSAPIPath+DependencyPath, nil, nil)
+	return c.modify("SetDependencies", "PUT", url, &discovery.AddDependenciesRequest{
+		Dependencies: []*discovery.ConsumerDependency{{Consumer: consumer, Providers: providers, Override: true}},
+	})
+}
+
+// modify sends the request changing the registry, a nil request sends no body
+func (c *Client) modify(name, method, url string, request interface{}) error {
+	var body []byte
+	if request != nil {
+		var err error
+		body, err = json.Marshal(request)
+		if err != nil {
+			return NewJSONException(err, string(body))
+		}
+	}
+	resp, err := c.httpDo(method, url, nil, body)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("%s failed, response is empty", name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return NewIOException(err)
+		}
+		return NewCommonException("%s failed, response StatusCode: %d, response body: %s", name, resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package sc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/go-chassis/openlog"
+)
+
+// PropertyCluster is the instance property set by MultiClient, its value is the name of the origin cluster
+const PropertyCluster = "sc-cluster"
+
+// ErrNoClusterAvailable means every cluster of MultiClient failed
+var ErrNoClusterAvailable = errors.New("no service center cluster is available")
+
+// ClusterOptions is the options of one service center cluster in MultiClient
+type ClusterOptions struct {
+	// Name identifies the cluster, it is set to the PropertyCluster of the instances found in it
+	Name    string
+	Options Options
+	// ConsumerID is the id of the consumer registered in this cluster, if it is empty,
+	// the consumer id given to the discovery method is used
+	ConsumerID string
+}
+
+type cluster struct {
+	name       string
+	consumerID string
+	client     *Client
+}
+
+// MultiClient discovers instances from several independent service center clusters,
+// the instances are merged and de-duplicated, one cluster being down does not fail the discovery
+type MultiClient struct {
+	clusters []*cluster
+}
+
+// NewMultiClient creates a client for each cluster
+func NewMultiClient(clusters []ClusterOptions) (*MultiClient, error) {
+	if len(clusters) == 0 {
+		return nil, errors.New("no cluster is given")
+	}
+	m := &MultiClient{}
+	names := make(map[string]bool, len(clusters))
+	for _, opt := range clusters {
+		if opt.Name == "" || names[opt.Name] {
+			m.Close()
+			return nil, fmt.Errorf("cluster name is empty or duplicated: %q", opt.Name)
+		}
+		names[opt.Name] = true
+		c, err := NewClient(opt.Options)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("create client of cluster %s failed: %s", opt.Name, err.Error())
+		}
+		m.clusters = append(m.clusters, &cluster{name: opt.Name, consumerID: opt.ConsumerID, client: c})
+	}
+	return m, nil
+}
+
+// Client returns the client of the cluster, nil if the cluster does not exist
+func (m *MultiClient) Client(name string) *Client {
+	for _, cl := range m.clusters {
+		if cl.name == name {
+			return cl.client
+		}
+	}
+	return nil
+}
+
+// Close closes all the clients
+func (m *MultiClient) Close() error {
+	var errs []string
+	for _, cl := range m.clusters {
+		if err := cl.client.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", cl.name, err.Error()))
+		}
+	}
+	if len(errs) != 0 {
+		return NewCommonException("close clients failed, %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (cl *cluster) consumer(consumerID string) string {
+	if cl.consumerID != "" {
+		return cl.consumerID
+	}
+	return consumerID
+}
+
+// fanOut calls fn on every cluster concurrently, it fails only if all the clusters fail
+func (m *MultiClient) fanOut(fn func(i int, cl *cluster) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(m.clusters))
+	for i, cl := range m.clusters {
+		wg.Add(1)
+		go func(i int, cl *cluster) {
+			defer wg.Done()
+			errs[i] = fn(i, cl)
+		}(i, cl)
+	}
+	wg.Wait()
+	var msgs []string
+	for i, err := range errs {
+		if err != nil {
+			openlog.Warn(fmt.Sprintf("cluster %s failed: %s", m.clusters[i].name, err.Error()))
+			msgs = append(msgs, fmt.Sprintf("%s: %s", m.clusters[i].name, err.Error()))
+		}
+	}
+	if len(msgs) == len(m.clusters) {
+		return fmt.Errorf("%w, %s", ErrNoClusterAvailable, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// FindInstances finds the instances of the service in all the clusters,
+// the revision is not used, because each cluster has its own revision
+func (m *MultiClient) FindInstances(consumerID, appID, microServiceName string,
+	opts ...CallOption) ([]*discovery.MicroServiceInstance, error) {
+	opts = append(opts, WithoutRevision())
+	results := make([][]*discovery.MicroServiceInstance, len(m.clusters))
+	err := m.fanOut(func(i int, cl *cluster) error {
+		rst, err := cl.client.FindInstances(cl.consumer(consumerID), appID, microServiceName, opts...)
+		if err == ErrMicroServiceNotExists {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		results[i] = tagInstances(cl.name, rst.Instances)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeInstances(results...), nil
+}
+
+// BatchFindInstances finds the instances of the services in all the clusters,
+// the result of each key is merged in the same order as keys
+func (m *MultiClient) BatchFindInstances(consumerID string, keys []*discovery.FindService,
+	opts ...CallOption) ([][]*discovery.MicroServiceInstance, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyCriteria
+	}
+	opts = append(opts, WithoutRevision())
+	results := make([][][]*discovery.MicroServiceInstance, len(m.clusters))
+	err := m.fanOut(func(i int, cl *cluster) error {
+		resp, err := cl.client.BatchFindInstances(cl.consumer(consumerID), keys, opts...)
+		if err != nil {
+			return err
+		}
+		found := make([][]*discovery.MicroServiceInstance, len(keys))
+		if resp != nil && resp.Instances != nil {
+			for _, updated := range resp.Instances.Updated {
+				if updated == nil || updated.Index < 0 || int(updated.Index) >= len(keys) {
+					continue
+				}
+				found[updated.Index] = tagInstances(cl.name, updated.Instances)
+			}
+		}
+		results[i] = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged := make([][]*discovery.MicroServiceInstance, len(keys))
+	for i := range keys {
+		perCluster := make([][]*discovery.MicroServiceInstance, 0, len(results))
+		for _, found := range results {
+			if found != nil {
+				perCluster = append(perCluster, found[i])
+			}
+		}
+		merged[i] = mergeInstances(perCluster...)
+	}
+	return merged, nil
+}
+
+// tagInstances copies the instances with PropertyCluster set to the cluster name
+func tagInstances(name string, instances []*discovery.MicroServiceInstance) []*discovery.MicroServiceInstance {
+	tagged := make([]*discovery.MicroServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if instance == nil {
+			continue
+		}
+		copied := *instance
+		copied.Properties = make(map[string]string, len(instance.Properties)+1)
+		for k, v := range instance.Properties {
+			copied.Properties[k] = v
+		}
+		copied.Properties[PropertyCluster] = name
+		tagged = append(tagged, &copied)
+	}
+	return tagged
+}
+
+// mergeInstances merges the instances in order, an instance with the same id or the same endpoints
+// as a former one is a duplicate, for example, registered to several clusters by a dual-registration
+func mergeInstances(lists ...[]*discovery.MicroServiceInstance) []*discovery.MicroServiceInstance {
+	var merged []*discovery.MicroServiceInstance
+	seenIDs := make(map[string]bool)
+	seenEndpoints := make(map[string]bool)
+	for _, list := range lists {
+		for _, instance := range list {
+			endpoints := strings.Join(instance.Endpoints, ",")
+			if seenIDs[instance.InstanceId] || (endpoints != "" && seenEndpoints[endpoints]) {
+				continue
+			}
+			seenIDs[instance.InstanceId] = true
+			if endpoints != "" {
+				seenEndpoints[endpoints] = true
+			}
+			merged = append(merged, instance)
+		}
+	}
+	return merged
+}
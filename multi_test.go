@@ -0,0 +1,50 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func newInstancesServer(instances ...*discovery.MicroServiceInstance) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		b, _ := json.Marshal(&discovery.GetInstancesResponse{Instances: instances})
+		writer.Write(b)
+	}))
+}
+
+func TestMultiClient_FindInstances(t *testing.T) {
+	shared := &discovery.MicroServiceInstance{InstanceId: "shared", Endpoints: []string{"rest://10.0.0.1:8080"}}
+	region1 := newInstancesServer(shared, &discovery.MicroServiceInstance{InstanceId: "1", Endpoints: []string{"rest://10.0.0.2:8080"}})
+	defer region1.Close()
+	region2 := newInstancesServer(shared, &discovery.MicroServiceInstance{InstanceId: "2", Endpoints: []string{"rest://10.0.0.3:8080"}})
+	defer region2.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	m, err := sc.NewMultiClient([]sc.ClusterOptions{
+		{Name: "region1", Options: sc.Options{Endpoints: []string{region1.Listener.Addr().String()}}},
+		{Name: "region2", Options: sc.Options{Endpoints: []string{region2.Listener.Addr().String()}}},
+		{Name: "down", Options: sc.Options{Endpoints: []string{down.Listener.Addr().String()}}},
+	})
+	assert.NoError(t, err)
+	defer m.Close()
+
+	instances, err := m.FindInstances("consumer", "default", "provider")
+	assert.NoError(t, err)
+	assert.Len(t, instances, 3)
+	origins := map[string]string{}
+	for _, instance := range instances {
+		origins[instance.InstanceId] = instance.Properties[sc.PropertyCluster]
+	}
+	assert.Equal(t, map[string]string{"shared": "region1", "1": "region1", "2": "region2"}, origins)
+
+	_, err = sc.NewMultiClient([]sc.ClusterOptions{{Name: "a"}, {Name: "a"}})
+	assert.Error(t, err)
+}
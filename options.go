@@ -15,6 +15,8 @@ type Options struct {
 	EnableSSL bool
 	Timeout   time.Duration
 	TLSConfig *tls.Config
+	// TLS builds TLSConfig from files when TLSConfig is nil, the certificates are reloaded when the files change
+	TLS *TLSOptions
 	// Other options can be stored in a context
 	Context         context.Context
 	Compressed      bool
@@ -24,6 +26,39 @@ type Options struct {
 	AuthToken       string
 	TokenExpiration time.Duration
 	SignRequest     func(*http.Request) error
+	// CredentialProvider supplies the token or user on each request, it takes precedence over AuthToken and AuthUser
+	CredentialProvider CredentialProvider
+	// AutoSyncEndpoints periodically refreshes the address pool with the members of SC cluster,
+	// the discovered endpoints are merged with Endpoints
+	AutoSyncEndpoints bool
+	// SyncEndpointsInterval is the interval of AutoSyncEndpoints, default is DefaultSyncEndpointsInterval
+	SyncEndpointsInterval time.Duration
+	// HeartbeatInterval is the interval of ping messages sent by WSHeartbeat,
+	// and of Heartbeat if it falls back to http, default is DefaultLeaseRenewalInterval seconds
+	HeartbeatInterval time.Duration
+	// OutlierDetection enables ejecting the instances which failed consecutively from discovery results,
+	// the call results are reported by Client.ReportCallResult
+	OutlierDetection *OutlierDetectionOptions
+	// DualEngine enables switching to the peer engine reported by CheckPeerStatus when the current engine is unhealthy
+	DualEngine *DualEngineOptions
+	// Hedging enables sending a second request to another address for the read-only discovery calls
+	// which do not respond in time, the counters are returned by Client.HedgingStats
+	Hedging *HedgingOptions
+	// DeduplicateReads collapses the identical read-only discovery requests in flight into one request,
+	// the result is shared by the callers
+	DeduplicateReads bool
+	// RateLimits limits the requests to service-center by operation class, the classes not in it are unlimited
+	RateLimits map[OperationClass]RateLimitOptions
+	// RegistrationJitter delays the first registration request by a random time up to it,
+	// so that the pods restarting together do not register at the same moment
+	RegistrationJitter time.Duration
+	// MaxThrottledRetries is the number of retries of a request rejected with 429 after Retry-After,
+	// default is DefaultMaxThrottledRetries, a negative value disables retrying
+	MaxThrottledRetries int
+	// Environment is the default environment of the providers found by FindInstances and BatchFindInstances,
+	// and of the services checked by GetMicroServiceID, like development and production.
+	// It is used when neither the call nor the arguments give an environment, and the call is not WithExactEnvironment.
+	Environment string
 }
 
 // CallOptions is options when you call a API
@@ -32,6 +67,15 @@ type CallOptions struct {
 	Revision        string
 	WithGlobal      bool
 	Address         string
+	// WithSchemaContent returns the content of schemas by ListSchemas
+	WithSchemaContent bool
+	// Environment is the environment of the providers found, it overrides Options.Environment
+	Environment string
+	// ExactEnvironment uses the environment given by the arguments as is, the empty one means no environment,
+	// neither Environment nor Options.Environment is used
+	ExactEnvironment bool
+	// VersionRule is the versions of the provider found by FindInstances, all versions if it is nil
+	VersionRule *VersionRule
 }
 
 // WithoutRevision ignore current revision number
@@ -55,6 +99,35 @@ func WithGlobal() CallOption {
 	}
 }
 
+// WithSchemaContent lists schemas with their content, by default only the ids and summaries are returned
+func WithSchemaContent() CallOption {
+	return func(o *CallOptions) {
+		o.WithSchemaContent = true
+	}
+}
+
+// WithEnvironment finds the providers in the environment, like development and production
+func WithEnvironment(env string) CallOption {
+	return func(o *CallOptions) {
+		o.Environment = env
+	}
+}
+
+// WithExactEnvironment checks the service in exactly the environment of the arguments, like RegisterOrGetService,
+// so that the services without environment are found by a client with Options.Environment
+func WithExactEnvironment() CallOption {
+	return func(o *CallOptions) {
+		o.ExactEnvironment = true
+	}
+}
+
+// WithVersionRule finds the instances of the provider versions matching the rule
+func WithVersionRule(rule VersionRule) CallOption {
+	return func(o *CallOptions) {
+		o.VersionRule = &rule
+	}
+}
+
 // WithAddress query resources with the sc address
 func WithAddress(address string) CallOption {
 	return func(o *CallOptions) {
@@ -64,3 +137,68 @@ func WithAddress(address string) CallOption {
 
 // CallOption is receiver for options and chang the attribute of it
 type CallOption func(*CallOptions)
+
+// RegisterOptions is options when you register a service
+type RegisterOptions struct {
+	UpdateProperties bool
+}
+
+// RegisterOption is receiver for options and chang the attribute of it
+type RegisterOption func(*RegisterOptions)
+
+// WithPropertiesUpdate updates the properties of the service if it is already registered
+func WithPropertiesUpdate() RegisterOption {
+	return func(o *RegisterOptions) {
+		o.UpdateProperties = true
+	}
+}
+
+// ReconcileOptions is options when you reconcile a manifest
+type ReconcileOptions struct {
+	// DryRun plans the changes without applying them
+	DryRun bool
+	// Prune deletes the services in the apps and environments of the manifest which are not declared
+	Prune bool
+}
+
+// ReconcileOption is receiver for options and chang the attribute of it
+type ReconcileOption func(*ReconcileOptions)
+
+// WithDryRun only plans the changes
+func WithDryRun() ReconcileOption {
+	return func(o *ReconcileOptions) {
+		o.DryRun = true
+	}
+}
+
+// WithPrune deletes the undeclared services without instances
+func WithPrune() ReconcileOption {
+	return func(o *ReconcileOptions) {
+		o.Prune = true
+	}
+}
+
+// DrainOptions is options when you drain an instance
+type DrainOptions struct {
+	// PollInterval is the interval of checking whether the status change is applied, DefaultDrainPollInterval if it is 0
+	PollInterval time.Duration
+	// PropagationDelay is the time given to watchers to receive the status change, DefaultDrainPropagationDelay if it is 0
+	PropagationDelay time.Duration
+}
+
+// DrainOption is receiver for options and chang the attribute of it
+type DrainOption func(*DrainOptions)
+
+// WithDrainPollInterval sets the interval of checking the status change
+func WithDrainPollInterval(d time.Duration) DrainOption {
+	return func(o *DrainOptions) {
+		o.PollInterval = d
+	}
+}
+
+// WithDrainPropagationDelay sets the time given to watchers to receive the status change
+func WithDrainPropagationDelay(d time.Duration) DrainOption {
+	return func(o *DrainOptions) {
+		o.PropagationDelay = d
+	}
+}
@@ -0,0 +1,218 @@
+package sc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/go-chassis/openlog"
+)
+
+const (
+	// EventEject is the action of an instance being ejected
+	EventEject string = "EJECT"
+	// EventRestore is the action of an ejected instance being restored
+	EventRestore string = "RESTORE"
+
+	// DefaultConsecutiveFailures is the default number of consecutive failures to eject an instance
+	DefaultConsecutiveFailures = 5
+	// DefaultEjectionTime is the default time an instance is ejected for
+	DefaultEjectionTime = 30 * time.Second
+)
+
+// OutlierDetectionOptions is the options of OutlierDetector
+type OutlierDetectionOptions struct {
+	// ConsecutiveFailures is the number of consecutive failures to eject an instance, default is DefaultConsecutiveFailures
+	ConsecutiveFailures int
+	// EjectionTime is the time an instance is ejected for, default is DefaultEjectionTime
+	EjectionTime time.Duration
+	// SlowCallThreshold counts a successful call slower than it as a failure, zero means disabled
+	SlowCallThreshold time.Duration
+	// OnEjection receives the eject and restore events
+	OnEjection func(e *EjectionEvent)
+}
+
+// EjectionEvent is a struct to store the ejection event information
+type EjectionEvent struct {
+	Action     string
+	InstanceID string
+	// Reason is the last failure before the instance is ejected
+	Reason string
+	// Until is the time the ejection ends
+	Until time.Time
+}
+
+type outlierStat struct {
+	failures     int
+	lastFailure  time.Time
+	ejectedUntil time.Time
+}
+
+// OutlierDetector is fed by the result of calls to instances,
+// and ejects the instances which failed consecutively from the lists the client hands out for a while.
+// Only the instances failing or ejected are tracked, the failures not followed by others for EjectionTime are forgotten,
+// so the instances gone for good do not stay in the detector.
+type OutlierDetector struct {
+	opt       OutlierDetectionOptions
+	mutex     sync.Mutex
+	stats     map[string]*outlierStat
+	nextSweep time.Time
+}
+
+// NewOutlierDetector creates an outlier detector
+func NewOutlierDetector(opt OutlierDetectionOptions) *OutlierDetector {
+	if opt.ConsecutiveFailures <= 0 {
+		opt.ConsecutiveFailures = DefaultConsecutiveFailures
+	}
+	if opt.EjectionTime <= 0 {
+		opt.EjectionTime = DefaultEjectionTime
+	}
+	return &OutlierDetector{
+		opt:   opt,
+		stats: make(map[string]*outlierStat),
+	}
+}
+
+// Report records the result of a call to the instance, err is nil if the call succeeded
+func (d *OutlierDetector) Report(microServiceInstanceID string, err error, latency time.Duration) {
+	if err == nil && d.opt.SlowCallThreshold > 0 && latency > d.opt.SlowCallThreshold {
+		err = fmt.Errorf("slow call, latency %v exceeds %v", latency, d.opt.SlowCallThreshold)
+	}
+	var event *EjectionEvent
+	now := time.Now()
+	d.mutex.Lock()
+	d.sweep(now)
+	stat, ok := d.stats[microServiceInstanceID]
+	if err == nil {
+		if ok && stat.ejectedUntil.IsZero() {
+			delete(d.stats, microServiceInstanceID)
+		} else if ok {
+			stat.failures = 0
+		}
+		d.mutex.Unlock()
+		return
+	}
+	if !ok {
+		stat = &outlierStat{}
+		d.stats[microServiceInstanceID] = stat
+	}
+	stat.failures++
+	stat.lastFailure = now
+	if stat.failures >= d.opt.ConsecutiveFailures && !now.Before(stat.ejectedUntil) {
+		stat.ejectedUntil = now.Add(d.opt.EjectionTime)
+		stat.failures = 0
+		event = &EjectionEvent{
+			Action:     EventEject,
+			InstanceID: microServiceInstanceID,
+			Reason:     err.Error(),
+			Until:      stat.ejectedUntil,
+		}
+	}
+	d.mutex.Unlock()
+	d.notify(event)
+}
+
+// sweep forgets the instances neither ejected nor failed within EjectionTime, at most once in EjectionTime
+func (d *OutlierDetector) sweep(now time.Time) {
+	if now.Before(d.nextSweep) {
+		return
+	}
+	d.nextSweep = now.Add(d.opt.EjectionTime)
+	for id, stat := range d.stats {
+		if now.Before(stat.ejectedUntil) || now.Sub(stat.lastFailure) < d.opt.EjectionTime {
+			continue
+		}
+		delete(d.stats, id)
+	}
+}
+
+// Ejected returns whether the instance is ejected now
+func (d *OutlierDetector) Ejected(microServiceInstanceID string) bool {
+	d.mutex.Lock()
+	ejected, event := d.check(microServiceInstanceID, time.Now())
+	d.mutex.Unlock()
+	d.notify(event)
+	return ejected
+}
+
+// Filter returns the instances which are not ejected,
+// if all of the instances are ejected, the instances are returned as is, so that the traffic is not cut off
+func (d *OutlierDetector) Filter(instances []*discovery.MicroServiceInstance) []*discovery.MicroServiceInstance {
+	if len(instances) == 0 {
+		return instances
+	}
+	now := time.Now()
+	var events []*EjectionEvent
+	kept := make([]*discovery.MicroServiceInstance, 0, len(instances))
+	d.mutex.Lock()
+	for _, instance := range instances {
+		if instance == nil {
+			continue
+		}
+		ejected, event := d.check(instance.InstanceId, now)
+		if event != nil {
+			events = append(events, event)
+		}
+		if !ejected {
+			kept = append(kept, instance)
+		}
+	}
+	d.mutex.Unlock()
+	for _, event := range events {
+		d.notify(event)
+	}
+	if len(kept) == 0 {
+		openlog.Warn("all the instances are ejected, ignore outlier detection")
+		return instances
+	}
+	return kept
+}
+
+// check returns whether the instance is ejected, and the restore event if the ejection just ended
+func (d *OutlierDetector) check(microServiceInstanceID string, now time.Time) (bool, *EjectionEvent) {
+	stat, ok := d.stats[microServiceInstanceID]
+	if !ok || stat.ejectedUntil.IsZero() {
+		return false, nil
+	}
+	if now.Before(stat.ejectedUntil) {
+		return true, nil
+	}
+	event := &EjectionEvent{
+		Action:     EventRestore,
+		InstanceID: microServiceInstanceID,
+		Until:      stat.ejectedUntil,
+	}
+	stat.ejectedUntil = time.Time{}
+	return false, event
+}
+
+func (d *OutlierDetector) notify(event *EjectionEvent) {
+	if event == nil {
+		return
+	}
+	openlog.Info(fmt.Sprintf("instance %s %s, until %v", event.InstanceID, event.Action, event.Until))
+	if d.opt.OnEjection != nil {
+		d.opt.OnEjection(event)
+	}
+}
+
+// ReportCallResult feeds the result of a call to the instance to the outlier detector,
+// it does nothing if Options.OutlierDetection is not set
+func (c *Client) ReportCallResult(microServiceInstanceID string, err error, latency time.Duration) {
+	if c.outlier == nil {
+		return
+	}
+	c.outlier.Report(microServiceInstanceID, err, latency)
+}
+
+// filterOutliers removes the ejected instances if outlier detection is enabled,
+// it returns whether any instance is removed, the revision of the list should not be kept then,
+// otherwise the ejected instances would not come back while service-center responds not modified
+func (c *Client) filterOutliers(instances []*discovery.MicroServiceInstance) ([]*discovery.MicroServiceInstance, bool) {
+	if c.outlier == nil {
+		return instances, false
+	}
+	kept := c.outlier.Filter(instances)
+	return kept, len(kept) != len(instances)
+}
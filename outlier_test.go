@@ -0,0 +1,94 @@
+package sc_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestOutlierDetector(t *testing.T) {
+	var events []*sc.EjectionEvent
+	d := sc.NewOutlierDetector(sc.OutlierDetectionOptions{
+		ConsecutiveFailures: 2,
+		EjectionTime:        200 * time.Millisecond,
+		SlowCallThreshold:   time.Second,
+		OnEjection: func(e *sc.EjectionEvent) {
+			events = append(events, e)
+		},
+	})
+	instances := []*discovery.MicroServiceInstance{{InstanceId: "1"}, {InstanceId: "2"}}
+
+	t.Run("failures not consecutive, should not eject", func(t *testing.T) {
+		d.Report("1", errors.New("refused"), 0)
+		d.Report("1", nil, time.Millisecond)
+		d.Report("1", errors.New("refused"), 0)
+		assert.False(t, d.Ejected("1"))
+		assert.Len(t, d.Filter(instances), 2)
+	})
+	t.Run("slow calls are failures, should eject", func(t *testing.T) {
+		d.Report("1", nil, 2*time.Second)
+		assert.True(t, d.Ejected("1"))
+		kept := d.Filter(instances)
+		assert.Len(t, kept, 1)
+		assert.Equal(t, "2", kept[0].InstanceId)
+		assert.Equal(t, sc.EventEject, events[0].Action)
+	})
+	t.Run("all instances ejected, should return all", func(t *testing.T) {
+		d.Report("2", errors.New("refused"), 0)
+		d.Report("2", errors.New("refused"), 0)
+		assert.Len(t, d.Filter(instances), 2)
+	})
+	t.Run("ejection time elapsed, should restore", func(t *testing.T) {
+		time.Sleep(250 * time.Millisecond)
+		assert.False(t, d.Ejected("1"))
+		assert.Equal(t, sc.EventRestore, events[len(events)-1].Action)
+	})
+}
+
+func TestClient_FindInstancesOutliers(t *testing.T) {
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Query().Get("rev") == "r1" {
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writer.Header().Set(sc.HeaderRevision, "r1")
+		writer.Write([]byte(`{"instances":[{"instanceId":"1"},{"instanceId":"2"}]}`))
+	}))
+	defer scServer.Close()
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+		OutlierDetection: &sc.OutlierDetectionOptions{
+			ConsecutiveFailures: 1,
+			EjectionTime:        200 * time.Millisecond,
+		},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	result, err := c.FindInstances("consumer", "default", "provider")
+	assert.NoError(t, err)
+	assert.Len(t, result.Instances, 2)
+	assert.Equal(t, "r1", result.Revision)
+
+	c.ReportCallResult("1", errors.New("refused"), 0)
+	result, err = c.FindInstances("consumer", "default", "provider", sc.WithRevision(result.Revision))
+	assert.Equal(t, sc.ErrNotModified, err)
+	result, err = c.FindInstances("consumer", "default", "provider")
+	assert.NoError(t, err)
+	assert.Len(t, result.Instances, 1)
+	// the revision of the filtered list is dropped, so the next query gets the ejected instance back
+	assert.Empty(t, result.Revision)
+
+	time.Sleep(250 * time.Millisecond)
+	result, err = c.FindInstances("consumer", "default", "provider", sc.WithRevision(result.Revision))
+	assert.NoError(t, err)
+	assert.Len(t, result.Instances, 2)
+	assert.Equal(t, "r1", result.Revision)
+}
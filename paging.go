@@ -0,0 +1,325 @@
+package sc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+// DefaultPageSize is the default number of items fetched in one page by iterators
+const DefaultPageSize = 100
+
+// ServiceFilter filters the services listed by ListMicroServices.
+// The filter is sent to service-center and also applied on the client,
+// so that the result is correct even if service-center ignores some of the conditions.
+type ServiceFilter struct {
+	AppID             string
+	ServiceNamePrefix string
+	Environment       string
+	// Framework is the name of framework, like go-chassis
+	Framework string
+}
+
+// Match returns whether the service satisfies the filter
+func (f ServiceFilter) Match(ms *discovery.MicroService) bool {
+	if ms == nil {
+		return false
+	}
+	if f.AppID != "" && ms.AppId != f.AppID {
+		return false
+	}
+	if f.ServiceNamePrefix != "" && !strings.HasPrefix(ms.ServiceName, f.ServiceNamePrefix) {
+		return false
+	}
+	if f.Environment != "" && ms.Environment != f.Environment {
+		return false
+	}
+	if f.Framework != "" && (ms.Framework == nil || ms.Framework.Name != f.Framework) {
+		return false
+	}
+	return true
+}
+
+func (f ServiceFilter) params() []URLParameter {
+	return []URLParameter{
+		{"appId": f.AppID},
+		{"serviceNamePrefix": f.ServiceNamePrefix},
+		{"env": f.Environment},
+		{"framework": f.Framework},
+	}
+}
+
+func pageParams(offset, limit int) []URLParameter {
+	return []URLParameter{
+		{"offset": strconv.Itoa(offset)},
+		{"limit": strconv.Itoa(limit)},
+	}
+}
+
+// ListMicroServices returns the page of the services matching the filter, starting at offset with at most limit services.
+// The page is requested from service-center, if service-center ignores the page parameters and returns
+// more than limit services, the page is cut on the client.
+func (c *Client) ListMicroServices(filter ServiceFilter, offset, limit int, opts ...CallOption) ([]*discovery.MicroService, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, errors.New("invalid page parameter")
+	}
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	services, err := c.listMicroServices(filter, pageParams(offset, limit), copts)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*discovery.MicroService, 0, len(services))
+	for _, ms := range services {
+		if filter.Match(ms) {
+			matched = append(matched, ms)
+		}
+	}
+	if len(services) <= limit {
+		return matched, nil
+	}
+	// all the services are returned
+	if offset >= len(matched) {
+		return []*discovery.MicroService{}, nil
+	}
+	matched = matched[offset:]
+	if limit < len(matched) {
+		matched = matched[:limit]
+	}
+	return matched, nil
+}
+
+// listMicroServices returns the services of the page, the filter is only sent to service-center
+func (c *Client) listMicroServices(filter ServiceFilter, page []URLParameter, copts *CallOptions) ([]*discovery.MicroService, error) {
+	url := c.formatURL(MSAPIPath+MicroservicePath, append(filter.params(), page...), copts)
+	resp, err := c.readDo("GET", url, nil, nil, copts)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("ListMicroServices failed, response is empty")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewIOException(err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var response discovery.GetServicesResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, NewJSONException(err, string(body))
+		}
+		return response.Services, nil
+	}
+	return nil, fmt.Errorf("ListMicroServices failed, response StatusCode: %d, response body: %s", resp.StatusCode, string(body))
+}
+
+// listMicroServiceInstances returns a page of the instances of provider
+func (c *Client) listMicroServiceInstances(consumerID, providerID string, offset, limit int, copts *CallOptions) ([]*discovery.MicroServiceInstance, error) {
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s", MSAPIPath, MicroservicePath, providerID, InstancePath),
+		pageParams(offset, limit), copts)
+	resp, err := c.readDo("GET", url, http.Header{
+		"X-ConsumerId": []string{consumerID},
+	}, nil, copts)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("list instances failed, response is empty, ConsumerId/ProviderId = %s/%s", consumerID, providerID)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewIOException(err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var response discovery.GetInstancesResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return nil, NewJSONException(err, string(body))
+		}
+		return response.Instances, nil
+	}
+	return nil, fmt.Errorf("list instances failed, ConsumerId/ProviderId: %s/%s, response StatusCode: %d, response body: %s",
+		consumerID, providerID, resp.StatusCode, string(body))
+}
+
+// pager keeps the paging state shared by iterators.
+// If service-center does not support paging, the first response contains all the items,
+// the pager detects it by the size of the page or by a page without unseen items and stops.
+type pager struct {
+	pageSize int
+	offset   int
+	done     bool
+	seen     map[string]bool
+}
+
+func newPager(pageSize int) *pager {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &pager{pageSize: pageSize, seen: make(map[string]bool)}
+}
+
+// advance records a fetched page of ids and returns the indexes of unseen items
+func (p *pager) advance(ids []string) []int {
+	var fresh []int
+	for i, id := range ids {
+		if p.seen[id] {
+			continue
+		}
+		p.seen[id] = true
+		fresh = append(fresh, i)
+	}
+	p.offset += len(ids)
+	if len(ids) != p.pageSize || len(fresh) == 0 {
+		p.done = true
+	}
+	return fresh
+}
+
+// ServiceIterator pages through the services matching a filter, the usage is like bufio.Scanner
+//
+//	it := c.NewServiceIterator(sc.ServiceFilter{AppID: "default"}, 100)
+//	for it.Next() {
+//		ms := it.Service()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type ServiceIterator struct {
+	c      *Client
+	filter ServiceFilter
+	copts  *CallOptions
+	pager  *pager
+	buf    []*discovery.MicroService
+	cur    *discovery.MicroService
+	err    error
+}
+
+// NewServiceIterator creates an iterator fetching pageSize services in each request
+func (c *Client) NewServiceIterator(filter ServiceFilter, pageSize int, opts ...CallOption) *ServiceIterator {
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	return &ServiceIterator{
+		c:      c,
+		filter: filter,
+		copts:  copts,
+		pager:  newPager(pageSize),
+	}
+}
+
+// Next advances to the next service, it returns false when there is no more service or an error occurred
+func (it *ServiceIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.err != nil || it.pager.done {
+			it.cur = nil
+			return false
+		}
+		services, err := it.c.listMicroServices(it.filter, pageParams(it.pager.offset, it.pager.pageSize), it.copts)
+		if err != nil {
+			it.err = err
+			continue
+		}
+		ids := make([]string, 0, len(services))
+		for _, ms := range services {
+			id := ""
+			if ms != nil {
+				id = ms.ServiceId
+			}
+			ids = append(ids, id)
+		}
+		for _, i := range it.pager.advance(ids) {
+			if it.filter.Match(services[i]) {
+				it.buf = append(it.buf, services[i])
+			}
+		}
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Service returns the current service
+func (it *ServiceIterator) Service() *discovery.MicroService {
+	return it.cur
+}
+
+// Err returns the error stopped the iteration
+func (it *ServiceIterator) Err() error {
+	return it.err
+}
+
+// InstanceIterator pages through the instances of a provider, the usage is the same as ServiceIterator
+type InstanceIterator struct {
+	c          *Client
+	consumerID string
+	providerID string
+	copts      *CallOptions
+	pager      *pager
+	buf        []*discovery.MicroServiceInstance
+	cur        *discovery.MicroServiceInstance
+	err        error
+}
+
+// NewInstanceIterator creates an iterator fetching pageSize instances of provider in each request
+func (c *Client) NewInstanceIterator(consumerID, providerID string, pageSize int, opts ...CallOption) *InstanceIterator {
+	copts := &CallOptions{}
+	for _, opt := range opts {
+		opt(copts)
+	}
+	return &InstanceIterator{
+		c:          c,
+		consumerID: consumerID,
+		providerID: providerID,
+		copts:      copts,
+		pager:      newPager(pageSize),
+	}
+}
+
+// Next advances to the next instance, it returns false when there is no more instance or an error occurred
+func (it *InstanceIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.err != nil || it.pager.done {
+			it.cur = nil
+			return false
+		}
+		instances, err := it.c.listMicroServiceInstances(it.consumerID, it.providerID, it.pager.offset, it.pager.pageSize, it.copts)
+		if err != nil {
+			it.err = err
+			continue
+		}
+		ids := make([]string, 0, len(instances))
+		for _, ins := range instances {
+			id := ""
+			if ins != nil {
+				id = ins.InstanceId
+			}
+			ids = append(ids, id)
+		}
+		for _, i := range it.pager.advance(ids) {
+			if instances[i] != nil {
+				it.buf = append(it.buf, instances[i])
+			}
+		}
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Instance returns the current instance
+func (it *InstanceIterator) Instance() *discovery.MicroServiceInstance {
+	return it.cur
+}
+
+// Err returns the error stopped the iteration
+func (it *InstanceIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,132 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func newPagingServer(total int, paging bool) *httptest.Server {
+	var services []*discovery.MicroService
+	for i := 0; i < total; i++ {
+		name := fmt.Sprintf("order%d", i)
+		if i%2 == 1 {
+			name = fmt.Sprintf("pay%d", i)
+		}
+		services = append(services, &discovery.MicroService{
+			ServiceId:   strconv.Itoa(i),
+			AppId:       "default",
+			ServiceName: name,
+		})
+	}
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var matched []*discovery.MicroService
+		for _, ms := range services {
+			if strings.HasPrefix(ms.ServiceName, request.URL.Query().Get("serviceNamePrefix")) {
+				matched = append(matched, ms)
+			}
+		}
+		begin, end := pageRange(request, len(matched), paging)
+		b, _ := json.Marshal(&discovery.GetServicesResponse{Services: matched[begin:end]})
+		writer.Write(b)
+	}))
+}
+
+// pageRange returns the range of the page requested, all the items if paging is not supported
+func pageRange(request *http.Request, total int, paging bool) (begin, end int) {
+	if !paging || request.URL.Query().Get("limit") == "" {
+		return 0, total
+	}
+	offset, _ := strconv.Atoi(request.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(request.URL.Query().Get("limit"))
+	if offset > total {
+		offset = total
+	}
+	if offset+limit > total {
+		return offset, total
+	}
+	return offset, offset + limit
+}
+
+func TestClient_NewServiceIterator(t *testing.T) {
+	for _, paging := range []bool{true, false} {
+		t.Run(fmt.Sprintf("paging supported: %v", paging), func(t *testing.T) {
+			scServer := newPagingServer(7, paging)
+			defer scServer.Close()
+			c, err := sc.NewClient(sc.Options{
+				Endpoints: []string{scServer.Listener.Addr().String()},
+			})
+			assert.NoError(t, err)
+
+			var names []string
+			it := c.NewServiceIterator(sc.ServiceFilter{ServiceNamePrefix: "order"}, 2)
+			for it.Next() {
+				names = append(names, it.Service().ServiceName)
+			}
+			assert.NoError(t, it.Err())
+			assert.Equal(t, []string{"order0", "order2", "order4", "order6"}, names)
+
+			services, err := c.ListMicroServices(sc.ServiceFilter{ServiceNamePrefix: "pay"}, 1, 2)
+			assert.NoError(t, err)
+			names = nil
+			for _, s := range services {
+				names = append(names, s.ServiceName)
+			}
+			assert.Equal(t, []string{"pay3", "pay5"}, names)
+
+			services, err = c.ListMicroServices(sc.ServiceFilter{ServiceNamePrefix: "pay"}, 3, 2)
+			assert.NoError(t, err)
+			assert.Empty(t, services)
+		})
+	}
+}
+
+func TestClient_NewInstanceIterator(t *testing.T) {
+	for _, paging := range []bool{true, false} {
+		t.Run(fmt.Sprintf("paging supported: %v", paging), func(t *testing.T) {
+			var instances []*discovery.MicroServiceInstance
+			for i := 0; i < 5; i++ {
+				instances = append(instances, &discovery.MicroServiceInstance{
+					InstanceId: strconv.Itoa(i),
+					ServiceId:  "provider",
+				})
+			}
+			scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if request.URL.Path != "/v4/default/registry/microservices/provider/instances" ||
+					request.Header.Get("X-ConsumerId") != "consumer" {
+					writer.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				begin, end := pageRange(request, len(instances), paging)
+				b, _ := json.Marshal(&discovery.GetInstancesResponse{Instances: instances[begin:end]})
+				writer.Write(b)
+			}))
+			defer scServer.Close()
+			c, err := sc.NewClient(sc.Options{
+				Endpoints: []string{scServer.Listener.Addr().String()},
+			})
+			assert.NoError(t, err)
+
+			var ids []string
+			it := c.NewInstanceIterator("consumer", "provider", 2)
+			for it.Next() {
+				ids = append(ids, it.Instance().InstanceId)
+			}
+			assert.NoError(t, it.Err())
+			assert.Equal(t, []string{"0", "1", "2", "3", "4"}, ids)
+
+			it = c.NewInstanceIterator("consumer", "unknown", 2)
+			assert.False(t, it.Next())
+			assert.Error(t, it.Err())
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package sc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/openlog"
+)
+
+// OperationClass groups the requests to service-center for rate limiting
+type OperationClass string
+
+const (
+	// ClassRegistration is the requests changing the registry, like RegisterService, AddSchemas and unregistering
+	ClassRegistration OperationClass = "registration"
+	// ClassDiscovery is the read-only requests, like FindInstances and GetMicroService
+	ClassDiscovery OperationClass = "discovery"
+	// ClassHeartbeat is the heartbeat requests over http
+	ClassHeartbeat OperationClass = "heartbeat"
+
+	// DefaultMaxThrottledRetries is the default number of retries of a request rejected with 429
+	DefaultMaxThrottledRetries = 3
+	// DefaultRetryAfter is the time waited before retrying a request rejected with 429 without Retry-After
+	DefaultRetryAfter = time.Second
+	// MaxRetryAfter caps the time waited for a Retry-After
+	MaxRetryAfter = time.Minute
+)
+
+// RateLimitOptions limits the requests of an operation class
+type RateLimitOptions struct {
+	// QPS is the rate of requests per second, zero means unlimited
+	QPS float64
+	// Burst is the number of requests sent at once when the bucket is full, default is 1
+	Burst int
+	// MaxConcurrent caps the requests waiting for the response, zero means unlimited
+	MaxConcurrent int
+}
+
+// limiter is a token bucket with a concurrency cap
+type limiter struct {
+	qps      float64
+	burst    float64
+	mutex    sync.Mutex
+	tokens   float64
+	last     time.Time
+	inFlight chan struct{}
+}
+
+func newLimiter(opt RateLimitOptions) *limiter {
+	l := &limiter{qps: opt.QPS, burst: float64(opt.Burst)}
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	l.tokens = l.burst
+	if opt.MaxConcurrent > 0 {
+		l.inFlight = make(chan struct{}, opt.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire waits for a token and a concurrency slot, the returned func releases the slot
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if err := l.waitToken(ctx); err != nil {
+		return nil, err
+	}
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.inFlight <- struct{}{}:
+		return func() { <-l.inFlight }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *limiter) waitToken(ctx context.Context) error {
+	if l.qps <= 0 {
+		return nil
+	}
+	for {
+		l.mutex.Lock()
+		now := time.Now()
+		if !l.last.IsZero() {
+			l.tokens += now.Sub(l.last).Seconds() * l.qps
+			if l.tokens > l.burst {
+				l.tokens = l.burst
+			}
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mutex.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.qps * float64(time.Second))
+		l.mutex.Unlock()
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// operationClass returns the class of a request, the token request is not limited
+func operationClass(method, rawURL string) (OperationClass, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case u.Path == TokenPath:
+		return "", false
+	case strings.HasSuffix(u.Path, HeartbeatPath) || strings.HasSuffix(u.Path, BatchHeartbeatPath):
+		return ClassHeartbeat, true
+	case method == http.MethodGet || strings.HasSuffix(u.Path, BatchInstancePath):
+		return ClassDiscovery, true
+	default:
+		return ClassRegistration, true
+	}
+}
+
+// throttle waits for the rate limit of the request, and for the registration jitter before the first registration
+func (c *Client) throttle(ctx context.Context, method, rawURL string) (func(), error) {
+	class, ok := operationClass(method, rawURL)
+	if !ok {
+		return func() {}, nil
+	}
+	if class == ClassRegistration && c.registrationJitter > 0 {
+		var err error
+		c.jitterOnce.Do(func() {
+			jitter := time.Duration(rand.Int63n(int64(c.registrationJitter))) // #nosec G404
+			openlog.Info(fmt.Sprintf("delay the registration %v", jitter))
+			err = sleepContext(ctx, jitter)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	l, ok := c.limiters[class]
+	if !ok {
+		return func() {}, nil
+	}
+	return l.acquire(ctx)
+}
+
+// retryAfter returns the time to wait before retrying a request rejected with 429
+func retryAfter(resp *http.Response) time.Duration {
+	d := DefaultRetryAfter
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			d = time.Duration(seconds) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			d = time.Until(t)
+		}
+	}
+	if d < 0 {
+		d = 0
+	}
+	if d > MaxRetryAfter {
+		d = MaxRetryAfter
+	}
+	return d
+}
@@ -0,0 +1,107 @@
+package sc_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestClient_RateLimits(t *testing.T) {
+	var inFlight, maxInFlight int32
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		writer.Write([]byte(`{"instances":[]}`))
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+		RateLimits: map[sc.OperationClass]sc.RateLimitOptions{
+			sc.ClassDiscovery: {QPS: 20, Burst: 2, MaxConcurrent: 1},
+		},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetMicroServiceInstances("consumer", "provider")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxInFlight))
+	// 6 requests of 50ms are sent one by one
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(300*time.Millisecond))
+
+	t.Run("the other classes are unlimited", func(t *testing.T) {
+		atomic.StoreInt32(&maxInFlight, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Heartbeat("sid", "iid")
+			}()
+		}
+		wg.Wait()
+		assert.Greater(t, atomic.LoadInt32(&maxInFlight), int32(1))
+	})
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	var calls int32
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			writer.Header().Set("Retry-After", "1")
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writer.Write([]byte(`{"instances":[]}`))
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	start := time.Now()
+	_, err = c.GetMicroServiceInstances("consumer", "provider")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+
+	t.Run("retrying can be disabled", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		c, err := sc.NewClient(sc.Options{
+			Endpoints:           []string{scServer.Listener.Addr().String()},
+			MaxThrottledRetries: -1,
+		})
+		assert.NoError(t, err)
+		defer c.Close()
+		_, err = c.GetMicroServiceInstances("consumer", "provider")
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
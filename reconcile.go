@@ -0,0 +1,545 @@
+package sc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-chassis/cari/discovery"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidManifest means the manifest is malformed or misses required fields
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+const (
+	// defaultApp is the app of the services declared without app
+	defaultApp = "default"
+	// serviceCenterName is the name of the service registered by service center itself
+	serviceCenterName = "SERVICECENTER"
+)
+
+// the actions of a plan
+const (
+	PlanCreate = "create"
+	PlanUpdate = "update"
+	PlanDelete = "delete"
+)
+
+// the resources changed by a plan
+const (
+	ResourceService      = "service"
+	ResourceProperties   = "properties"
+	ResourceSchema       = "schema"
+	ResourceTag          = "tag"
+	ResourceRule         = "rule"
+	ResourceDependencies = "dependencies"
+)
+
+// Manifest is the desired state of services, for example, kept in a git repository
+type Manifest struct {
+	Services []*ManifestService `yaml:"services"`
+}
+
+// ManifestService is the desired state of a service. The omitted properties, tags, rules, schemas and dependencies
+// are not managed, and the empty ones delete the existing resources.
+// The description is not declared, because service center can not change it after the service is registered.
+type ManifestService struct {
+	AppID        string                `yaml:"app"`
+	Name         string                `yaml:"name"`
+	Version      string                `yaml:"version"`
+	Environment  string                `yaml:"env"`
+	Properties   map[string]string     `yaml:"properties"`
+	Tags         map[string]string     `yaml:"tags"`
+	Rules        []*ManifestRule       `yaml:"rules"`
+	Schemas      []*ManifestSchema     `yaml:"schemas"`
+	Dependencies []*ManifestDependency `yaml:"dependencies"`
+}
+
+// ManifestRule is a black or white list rule
+type ManifestRule struct {
+	// Type is BLACK or WHITE
+	Type        string `yaml:"type"`
+	Attribute   string `yaml:"attribute"`
+	Pattern     string `yaml:"pattern"`
+	Description string `yaml:"description"`
+}
+
+// ManifestSchema is a schema given by content or by a file relative to the manifest
+type ManifestSchema struct {
+	ID      string `yaml:"id"`
+	Content string `yaml:"content"`
+	File    string `yaml:"file"`
+}
+
+// ManifestDependency is a provider of the service, the version is matched exactly with the registered one
+type ManifestDependency struct {
+	AppID       string `yaml:"app"`
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	Environment string `yaml:"env"`
+}
+
+func (s *ManifestService) key() *discovery.MicroServiceKey {
+	return &discovery.MicroServiceKey{AppId: s.AppID, ServiceName: s.Name, Version: s.Version, Environment: s.Environment}
+}
+
+// serviceKeyString formats the key like app/name/version@env
+func serviceKeyString(key *discovery.MicroServiceKey) string {
+	s := key.AppId + "/" + key.ServiceName + "/" + key.Version
+	if key.Environment != "" {
+		s += "@" + key.Environment
+	}
+	return s
+}
+
+// ParseManifest decodes and validates the manifest in YAML, the schema files are not read
+func ParseManifest(content []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidManifest, err.Error())
+	}
+	var problems []string
+	declared := make(map[string]bool, len(m.Services))
+	for i, s := range m.Services {
+		if s == nil {
+			problems = append(problems, fmt.Sprintf("service %d is empty", i))
+			continue
+		}
+		if s.AppID == "" {
+			s.AppID = defaultApp
+		}
+		if s.Name == "" || s.Version == "" {
+			problems = append(problems, fmt.Sprintf("service %d: name and version are required", i))
+			continue
+		}
+		name := serviceKeyString(s.key())
+		if declared[name] {
+			problems = append(problems, fmt.Sprintf("service %s is declared more than once", name))
+		}
+		declared[name] = true
+		for _, r := range s.Rules {
+			if r == nil || (r.Type != "BLACK" && r.Type != "WHITE") || r.Attribute == "" || r.Pattern == "" {
+				problems = append(problems, fmt.Sprintf("service %s: rule requires type BLACK or WHITE, attribute and pattern", name))
+			}
+		}
+		for _, schema := range s.Schemas {
+			if schema == nil || schema.ID == "" || (schema.Content == "") == (schema.File == "") {
+				problems = append(problems, fmt.Sprintf("service %s: schema requires id and either content or file", name))
+			}
+		}
+		for _, d := range s.Dependencies {
+			if d == nil || d.Name == "" || d.Version == "" {
+				problems = append(problems, fmt.Sprintf("service %s: dependency requires name and version", name))
+				continue
+			}
+			if d.AppID == "" {
+				d.AppID = s.AppID
+			}
+		}
+	}
+	if len(problems) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidManifest, strings.Join(problems, "; "))
+	}
+	return &m, nil
+}
+
+// LoadManifest reads the manifest file, and the schema files relative to its directory
+func LoadManifest(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseManifest(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range m.Services {
+		for _, schema := range s.Schemas {
+			if schema.File == "" {
+				continue
+			}
+			file := schema.File
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(filepath.Dir(path), file)
+			}
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("schema %s of %s: %w", schema.ID, serviceKeyString(s.key()), err)
+			}
+			schema.Content = string(b)
+		}
+	}
+	return m, nil
+}
+
+// PlanAction is a change to be made by a plan
+type PlanAction struct {
+	Action   string
+	Resource string
+	// Service is the key of the service, like default/hello/1.0.0@development
+	Service string
+	// Name identifies the resource in the service, like schema id and tag key
+	Name string
+	// Applied is set after the action is applied successfully
+	Applied bool
+	apply   func() error
+}
+
+func (a *PlanAction) String() string {
+	sign := "+"
+	switch a.Action {
+	case PlanUpdate:
+		sign = "~"
+	case PlanDelete:
+		sign = "-"
+	}
+	s := fmt.Sprintf("%s %s %s", sign, a.Resource, a.Service)
+	if a.Name != "" {
+		s += " " + a.Name
+	}
+	return s
+}
+
+// Plan is the changes to make service center the same as a manifest
+type Plan struct {
+	Actions []*PlanAction
+}
+
+func (p *Plan) add(action, resource, service, name string, apply func() error) {
+	p.Actions = append(p.Actions, &PlanAction{Action: action, Resource: resource, Service: service, Name: name, apply: apply})
+}
+
+// String returns the actions in lines, + for creating, ~ for updating and - for deleting, followed by a summary
+func (p *Plan) String() string {
+	if len(p.Actions) == 0 {
+		return "No changes.\n"
+	}
+	var b strings.Builder
+	counts := make(map[string]int)
+	for _, a := range p.Actions {
+		b.WriteString(a.String())
+		b.WriteString("\n")
+		counts[a.Action]++
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to delete.\n", counts[PlanCreate], counts[PlanUpdate], counts[PlanDelete])
+	return b.String()
+}
+
+// Reconcile makes service center the same as the manifest, and returns the plan.
+// With WithDryRun, the plan is returned without being applied.
+// If an error occurs, the actions applied before it are marked in the plan.
+func (c *Client) Reconcile(ctx context.Context, m *Manifest, opts ...ReconcileOption) (*Plan, error) {
+	ropts := &ReconcileOptions{}
+	for _, opt := range opts {
+		opt(ropts)
+	}
+	p, err := c.PlanManifest(ctx, m, opts...)
+	if err != nil || ropts.DryRun {
+		return p, err
+	}
+	return p, c.ApplyPlan(ctx, p)
+}
+
+// PlanManifest compares the manifest with service center, and returns the changes to be applied by ApplyPlan
+func (c *Client) PlanManifest(ctx context.Context, m *Manifest, opts ...ReconcileOption) (*Plan, error) {
+	ropts := &ReconcileOptions{}
+	for _, opt := range opts {
+		opt(ropts)
+	}
+	p := &Plan{}
+	// the dependencies are changed after the providers are created
+	dependencies := &Plan{}
+	for _, s := range m.Services {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := c.planService(p, dependencies, s); err != nil {
+			return nil, fmt.Errorf("plan %s failed: %w", serviceKeyString(s.key()), err)
+		}
+	}
+	p.Actions = append(p.Actions, dependencies.Actions...)
+	if ropts.Prune {
+		if err := c.planPrune(p, m); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ApplyPlan applies the actions not applied yet in order, it stops at the first error
+func (c *Client) ApplyPlan(ctx context.Context, p *Plan) error {
+	for _, a := range p.Actions {
+		if a.Applied {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.apply(); err != nil {
+			return fmt.Errorf("%s failed: %w", a.String(), err)
+		}
+		a.Applied = true
+	}
+	return nil
+}
+
+// planned is a service being planned, the id is set when the service is created
+type planned struct {
+	service *ManifestService
+	name    string
+	id      string
+	detail  *discovery.ServiceDetail
+	schemas []*discovery.Schema
+}
+
+func (c *Client) planService(p, dependencies *Plan, s *ManifestService) error {
+	// the declared environment is exact, the default one of the client is not used
+	id, err := c.getMicroServiceID(s.AppID, s.Name, s.Version, s.Environment, &CallOptions{})
+	if err != nil {
+		return err
+	}
+	ps := &planned{service: s, name: serviceKeyString(s.key()), id: id, detail: &discovery.ServiceDetail{}}
+	if id == "" {
+		p.add(PlanCreate, ResourceService, ps.name, "", func() error {
+			ms := &discovery.MicroService{
+				AppId:       s.AppID,
+				ServiceName: s.Name,
+				Version:     s.Version,
+				Environment: s.Environment,
+				Properties:  s.Properties,
+			}
+			for _, schema := range s.Schemas {
+				ms.Schemas = append(ms.Schemas, schema.ID)
+			}
+			var err error
+			ps.id, err = c.RegisterService(ms)
+			return err
+		})
+	} else {
+		if ps.detail, err = c.GetServiceDetail(id, []GovernOption{GovernTags, GovernRules, GovernDependencies}); err != nil {
+			return err
+		}
+		if s.Schemas != nil {
+			if ps.schemas, err = c.ListSchemas(id, WithSchemaContent()); err != nil {
+				return err
+			}
+		}
+		var properties map[string]string
+		if ps.detail.MicroService != nil {
+			properties = ps.detail.MicroService.Properties
+		}
+		if s.Properties != nil && (len(s.Properties) != 0 || len(properties) != 0) && !reflect.DeepEqual(s.Properties, properties) {
+			p.add(PlanUpdate, ResourceProperties, ps.name, "", func() error {
+				_, err := c.UpdateMicroServiceProperties(ps.id, &discovery.MicroService{Properties: s.Properties})
+				return err
+			})
+		}
+	}
+	planSchemas(c, p, ps)
+	planTags(c, p, ps)
+	planRules(c, p, ps)
+	planDependencies(c, dependencies, ps)
+	return nil
+}
+
+func planSchemas(c *Client, p *Plan, ps *planned) {
+	if ps.service.Schemas == nil {
+		return
+	}
+	existing := make(map[string]string, len(ps.schemas))
+	for _, schema := range ps.schemas {
+		existing[schema.SchemaId] = schema.Schema
+	}
+	declared := make(map[string]bool, len(ps.service.Schemas))
+	for _, schema := range ps.service.Schemas {
+		schema := schema
+		declared[schema.ID] = true
+		content, ok := existing[schema.ID]
+		if ok && content == schema.Content {
+			continue
+		}
+		action := PlanCreate
+		if ok {
+			action = PlanUpdate
+		}
+		p.add(action, ResourceSchema, ps.name, schema.ID, func() error {
+			return c.AddSchemas(ps.id, schema.ID, schema.Content)
+		})
+	}
+	for _, schema := range ps.schemas {
+		schemaID := schema.SchemaId
+		if declared[schemaID] {
+			continue
+		}
+		p.add(PlanDelete, ResourceSchema, ps.name, schemaID, func() error {
+			return c.DeleteSchema(ps.id, schemaID)
+		})
+	}
+}
+
+func planTags(c *Client, p *Plan, ps *planned) {
+	if ps.service.Tags == nil {
+		return
+	}
+	for _, k := range sortedKeys(ps.service.Tags) {
+		k, v := k, ps.service.Tags[k]
+		old, ok := ps.detail.Tags[k]
+		if ok && old == v {
+			continue
+		}
+		action := PlanCreate
+		if ok {
+			action = PlanUpdate
+		}
+		p.add(action, ResourceTag, ps.name, k, func() error {
+			return c.AddTags(ps.id, map[string]string{k: v})
+		})
+	}
+	for _, k := range sortedKeys(ps.detail.Tags) {
+		k := k
+		if _, ok := ps.service.Tags[k]; ok {
+			continue
+		}
+		p.add(PlanDelete, ResourceTag, ps.name, k, func() error {
+			return c.DeleteTags(ps.id, []string{k})
+		})
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func planRules(c *Client, p *Plan, ps *planned) {
+	if ps.service.Rules == nil {
+		return
+	}
+	// a rule is identified by type, attribute and pattern, the description is updated in place
+	ruleKey := func(ruleType, attribute, pattern string) string {
+		return ruleType + " " + attribute + " " + pattern
+	}
+	existing := make(map[string]*discovery.ServiceRule, len(ps.detail.Rules))
+	for _, rule := range ps.detail.Rules {
+		existing[ruleKey(rule.RuleType, rule.Attribute, rule.Pattern)] = rule
+	}
+	declared := make(map[string]bool, len(ps.service.Rules))
+	for _, rule := range ps.service.Rules {
+		rule := rule
+		key := ruleKey(rule.Type, rule.Attribute, rule.Pattern)
+		declared[key] = true
+		request := &discovery.AddOrUpdateServiceRule{
+			RuleType:    rule.Type,
+			Attribute:   rule.Attribute,
+			Pattern:     rule.Pattern,
+			Description: rule.Description,
+		}
+		old, ok := existing[key]
+		switch {
+		case !ok:
+			p.add(PlanCreate, ResourceRule, ps.name, key, func() error {
+				return c.AddRules(ps.id, []*discovery.AddOrUpdateServiceRule{request})
+			})
+		case old.Description != rule.Description:
+			ruleID := old.RuleId
+			p.add(PlanUpdate, ResourceRule, ps.name, key, func() error {
+				return c.UpdateRule(ps.id, ruleID, request)
+			})
+		}
+	}
+	for _, rule := range ps.detail.Rules {
+		key, ruleID := ruleKey(rule.RuleType, rule.Attribute, rule.Pattern), rule.RuleId
+		if declared[key] {
+			continue
+		}
+		p.add(PlanDelete, ResourceRule, ps.name, key, func() error {
+			return c.DeleteRules(ps.id, []string{ruleID})
+		})
+	}
+}
+
+func planDependencies(c *Client, p *Plan, ps *planned) {
+	if ps.service.Dependencies == nil {
+		return
+	}
+	existing := make([]string, 0, len(ps.detail.Providers))
+	for _, provider := range ps.detail.Providers {
+		existing = append(existing, serviceKeyString(&discovery.MicroServiceKey{AppId: provider.AppId,
+			ServiceName: provider.ServiceName, Version: provider.Version, Environment: provider.Environment}))
+	}
+	providers := make([]*discovery.MicroServiceKey, 0, len(ps.service.Dependencies))
+	declared := make([]string, 0, len(ps.service.Dependencies))
+	for _, d := range ps.service.Dependencies {
+		key := &discovery.MicroServiceKey{AppId: d.AppID, ServiceName: d.Name, Version: d.Version, Environment: d.Environment}
+		providers = append(providers, key)
+		declared = append(declared, serviceKeyString(key))
+	}
+	sort.Strings(existing)
+	sort.Strings(declared)
+	if reflect.DeepEqual(existing, declared) {
+		return
+	}
+	action := PlanUpdate
+	switch {
+	case len(existing) == 0:
+		action = PlanCreate
+	case len(declared) == 0:
+		action = PlanDelete
+	}
+	p.add(action, ResourceDependencies, ps.name, strings.Join(declared, ","), func() error {
+		return c.SetDependencies(ps.service.key(), providers)
+	})
+}
+
+// planPrune deletes the undeclared services in the apps and environments of the manifest,
+// the services with instances and service center itself are kept
+func (c *Client) planPrune(p *Plan, m *Manifest) error {
+	declared := make(map[string]bool, len(m.Services))
+	scopes := make(map[string]map[string]bool)
+	for _, s := range m.Services {
+		declared[serviceKeyString(s.key())] = true
+		if scopes[s.AppID] == nil {
+			scopes[s.AppID] = make(map[string]bool)
+		}
+		scopes[s.AppID][s.Environment] = true
+	}
+	apps := make([]string, 0, len(scopes))
+	for app := range scopes {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	for _, app := range apps {
+		services, err := c.GetAppServices(app, []GovernOption{GovernInstances})
+		if err != nil {
+			return fmt.Errorf("plan prune of app %s failed: %w", app, err)
+		}
+		for _, detail := range services {
+			ms := detail.MicroService
+			if ms == nil || ms.AppId != app || !scopes[app][ms.Environment] || ms.ServiceName == serviceCenterName ||
+				len(detail.Instances) != 0 {
+				continue
+			}
+			name := serviceKeyString(&discovery.MicroServiceKey{AppId: ms.AppId, ServiceName: ms.ServiceName,
+				Version: ms.Version, Environment: ms.Environment})
+			if declared[name] {
+				continue
+			}
+			serviceID := ms.ServiceId
+			p.add(PlanDelete, ResourceService, name, "", func() error {
+				_, err := c.UnregisterMicroService(serviceID)
+				return err
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package sc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+	"github.com/go-chassis/sc-client/internal/scfake"
+)
+
+const manifest = `
+services:
+- name: provider
+  version: 1.0.0
+  properties:
+    owner: team
+  tags:
+    zone: az1
+  rules:
+  - type: BLACK
+    attribute: ServiceName
+    pattern: bad
+    description: no bad
+  schemas:
+  - id: hello
+    content: |
+      swagger: "2.0"
+- name: consumer
+  version: 1.0.0
+  dependencies:
+  - name: provider
+    version: 1.0.0
+`
+
+func TestParseManifest(t *testing.T) {
+	m, err := sc.ParseManifest([]byte(manifest))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(m.Services))
+	assert.Equal(t, "default", m.Services[0].AppID)
+	assert.Equal(t, "default", m.Services[1].Dependencies[0].AppID)
+	assert.Nil(t, m.Services[1].Tags)
+
+	_, err = sc.ParseManifest([]byte("services:\n- name: a\n  version: 1.0.0\n- name: a\n  version: 1.0.0\n  rules:\n  - type: GREY\n"))
+	assert.True(t, errors.Is(err, sc.ErrInvalidManifest))
+	assert.Contains(t, err.Error(), "more than once")
+	assert.Contains(t, err.Error(), "BLACK or WHITE")
+}
+
+func TestClient_Reconcile(t *testing.T) {
+	s := scfake.New()
+	defer s.Close()
+	provider := s.AddService(&discovery.MicroService{AppId: "default", ServiceName: "provider", Version: "1.0.0"})
+	stale := s.AddService(&discovery.MicroService{AppId: "default", ServiceName: "stale", Version: "1.0.0"})
+	s.AddService(&discovery.MicroService{AppId: "default", ServiceName: "running", Version: "1.0.0"})
+	s.AddService(&discovery.MicroService{AppId: "other", ServiceName: "stale", Version: "1.0.0"})
+	s.Lock()
+	s.Services[provider].Tags["zone"] = "az0"
+	s.Services[provider].Tags["legacy"] = "true"
+	s.Services[provider].Rules = []*discovery.ServiceRule{
+		{RuleId: "r0", RuleType: "WHITE", Attribute: "ServiceName", Pattern: "old"},
+		{RuleId: "r1", RuleType: "BLACK", Attribute: "ServiceName", Pattern: "bad"},
+	}
+	s.Services[provider].Schemas["old"] = &discovery.Schema{SchemaId: "old", Schema: "old"}
+	for _, service := range s.Services {
+		if service.MicroService.ServiceName == "running" {
+			service.Instances = []*discovery.MicroServiceInstance{{InstanceId: "i"}}
+		}
+	}
+	s.Unlock()
+
+	c, err := sc.NewClient(sc.Options{Endpoints: []string{s.Addr()}})
+	assert.NoError(t, err)
+	defer c.Close()
+	m, err := sc.ParseManifest([]byte(manifest))
+	assert.NoError(t, err)
+
+	p, err := c.Reconcile(context.Background(), m, sc.WithDryRun(), sc.WithPrune())
+	assert.NoError(t, err)
+	assert.Equal(t, "~ properties default/provider/1.0.0\n"+
+		"+ schema default/provider/1.0.0 hello\n"+
+		"- schema default/provider/1.0.0 old\n"+
+		"~ tag default/provider/1.0.0 zone\n"+
+		"- tag default/provider/1.0.0 legacy\n"+
+		"~ rule default/provider/1.0.0 BLACK ServiceName bad\n"+
+		"- rule default/provider/1.0.0 WHITE ServiceName old\n"+
+		"+ service default/consumer/1.0.0\n"+
+		"+ dependencies default/consumer/1.0.0 default/provider/1.0.0\n"+
+		"- service default/stale/1.0.0\n"+
+		"Plan: 3 to create, 3 to update, 4 to delete.\n", p.String())
+	s.Lock()
+	assert.Empty(t, s.Requests)
+	s.Unlock()
+
+	p, err = c.Reconcile(context.Background(), m, sc.WithPrune())
+	assert.NoError(t, err)
+	for _, a := range p.Actions {
+		assert.True(t, a.Applied)
+	}
+	s.Lock()
+	assert.Equal(t, map[string]string{"zone": "az1"}, s.Services[provider].Tags)
+	assert.Equal(t, "team", s.Services[provider].MicroService.Properties["owner"])
+	if assert.Equal(t, 1, len(s.Services[provider].Rules)) {
+		assert.Equal(t, "r1", s.Services[provider].Rules[0].RuleId)
+		assert.Equal(t, "no bad", s.Services[provider].Rules[0].Description)
+	}
+	assert.Equal(t, "swagger: \"2.0\"\n", s.Services[provider].Schemas["hello"].Schema)
+	assert.Nil(t, s.Services[provider].Schemas["old"])
+	assert.Nil(t, s.Services[stale])
+	assert.NotNil(t, s.Find("other", "stale", "1.0.0", ""))
+	consumer := s.Find("default", "consumer", "1.0.0", "")
+	if assert.NotNil(t, consumer) {
+		assert.Equal(t, "provider", consumer.Providers[0].ServiceName)
+	}
+	s.Unlock()
+
+	p, err = c.Reconcile(context.Background(), m, sc.WithPrune())
+	assert.NoError(t, err)
+	assert.Equal(t, "No changes.\n", p.String())
+
+	t.Run("empty dependencies, should delete the existing ones", func(t *testing.T) {
+		m, err := sc.ParseManifest([]byte("services:\n- name: consumer\n  version: 1.0.0\n  dependencies: []\n"))
+		assert.NoError(t, err)
+		p, err := c.Reconcile(context.Background(), m)
+		assert.NoError(t, err)
+		assert.Equal(t, "- dependencies default/consumer/1.0.0\n"+
+			"Plan: 0 to create, 0 to update, 1 to delete.\n", p.String())
+		s.Lock()
+		assert.Equal(t, "PUT /v4/default/registry/dependencies", s.Requests[len(s.Requests)-1])
+		assert.Empty(t, s.Find("default", "consumer", "1.0.0", "").Providers)
+		s.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Reconcile(ctx, m)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -27,6 +27,31 @@ const (
 	DefaultLeaseRenewalInterval = 30
 )
 
+// InstanceStatus is the status of micro-service instance
+type InstanceStatus string
+
+const (
+	// InstanceUp means the instance is ready to serve
+	InstanceUp InstanceStatus = "UP"
+	// InstanceDown means the instance is not available
+	InstanceDown InstanceStatus = "DOWN"
+	// InstanceStarting means the instance is starting and not ready to serve
+	InstanceStarting InstanceStatus = "STARTING"
+	// InstanceOutOfService means the instance is alive but should not receive traffic
+	InstanceOutOfService InstanceStatus = "OUTOFSERVICE"
+	// InstanceTesting means the instance only receives test traffic
+	InstanceTesting InstanceStatus = "TESTING"
+)
+
+// Valid returns whether the status is supported by service-center
+func (s InstanceStatus) Valid() bool {
+	switch s {
+	case InstanceUp, InstanceDown, InstanceStarting, InstanceOutOfService, InstanceTesting:
+		return true
+	}
+	return false
+}
+
 // MicroServiceProvideResponse is a struct with provider information
 type MicroServiceProvideResponse struct {
 	Services []*discovery.MicroService `json:"providers,omitempty"`
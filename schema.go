@@ -0,0 +1,369 @@
+package sc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-chassis/cari/discovery"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrInvalidSchema means the schema is not a valid OpenAPI 2 or 3 document
+	ErrInvalidSchema = errors.New("invalid schema")
+	// ErrSchemaNotExists means the schema does not exist in the service
+	ErrSchemaNotExists = errors.New("schema does not exist")
+)
+
+// the methods of an OpenAPI path item, the other keys like parameters are not operations
+var schemaMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// SchemaDocument is the parsed OpenAPI 2 or 3 schema
+type SchemaDocument struct {
+	// OpenAPIVersion is the value of swagger in OpenAPI 2 or openapi in OpenAPI 3, like 2.0 or 3.0.1
+	OpenAPIVersion string
+	Title          string
+	// Version is the version of the API in info
+	Version string
+	// BasePath is the base path of OpenAPI 2, it is empty for OpenAPI 3
+	BasePath string
+	// Paths is the operations by path and by lower case method
+	Paths map[string]map[string]*SchemaOperation
+	// Raw is the whole document
+	Raw map[string]interface{}
+}
+
+// SchemaOperation is an operation of the schema
+type SchemaOperation struct {
+	OperationID string
+	Parameters  []*SchemaParameter
+	// RequestBodyRequired is whether the request body of OpenAPI 3 is required,
+	// the body of OpenAPI 2 is a parameter in body
+	RequestBodyRequired bool
+	// RequestBodyFields is the required properties of the request body schema, the local references are resolved
+	RequestBodyFields []string
+	// Responses is the status codes of the responses, like 200 and default
+	Responses []string
+}
+
+// SchemaParameter is a parameter of an operation
+type SchemaParameter struct {
+	Name string
+	// In is the location, like path, query, header and body
+	In       string
+	Required bool
+	// Type is the data type, like string and integer, it is empty for a parameter defined by schema reference
+	Type string
+}
+
+// IsOpenAPI3 returns whether the document is OpenAPI 3
+func (d *SchemaDocument) IsOpenAPI3() bool {
+	return strings.HasPrefix(d.OpenAPIVersion, "3.")
+}
+
+// ParseSchema parses and validates an OpenAPI 2 or 3 schema in YAML or JSON,
+// it checks the structure required by the specification, not the references and the data types
+func ParseSchema(content []byte) (*SchemaDocument, error) {
+	// JSON is a subset of YAML
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("%w: empty document", ErrInvalidSchema)
+	}
+	var raw map[string]interface{}
+	if err := root.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("%w: empty document", ErrInvalidSchema)
+	}
+	doc := &SchemaDocument{Raw: raw, Paths: make(map[string]map[string]*SchemaOperation)}
+	var problems []string
+	// the versions are read as written, the unquoted 2.0 is a float and would be 2
+	swagger, openapi := scalarText(&root, "swagger"), scalarText(&root, "openapi")
+	switch {
+	case swagger == "2.0":
+		doc.OpenAPIVersion = swagger
+		doc.BasePath = scalarString(raw["basePath"])
+	case strings.HasPrefix(openapi, "3."):
+		doc.OpenAPIVersion = openapi
+	default:
+		problems = append(problems, "swagger 2.0 or openapi 3.x is required")
+	}
+	info, ok := stringMap(raw["info"])
+	if !ok {
+		problems = append(problems, "info is required")
+	} else {
+		doc.Title = scalarString(info["title"])
+		doc.Version = scalarText(&root, "info", "version")
+		if doc.Title == "" {
+			problems = append(problems, "info.title is required")
+		}
+		if doc.Version == "" {
+			problems = append(problems, "info.version is required")
+		}
+	}
+	paths, ok := stringMap(raw["paths"])
+	if !ok {
+		problems = append(problems, "paths is required")
+	}
+	for path, item := range paths {
+		if !strings.HasPrefix(path, "/") {
+			problems = append(problems, fmt.Sprintf("path %s must begin with /", path))
+			continue
+		}
+		operations, ps := parsePathItem(raw, path, item)
+		problems = append(problems, ps...)
+		doc.Paths[path] = operations
+	}
+	if len(problems) != 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, strings.Join(problems, "; "))
+	}
+	return doc, nil
+}
+
+// ValidateSchema checks whether the content is a valid OpenAPI 2 or 3 schema in YAML or JSON
+func ValidateSchema(content string) error {
+	_, err := ParseSchema([]byte(content))
+	return err
+}
+
+func parsePathItem(doc map[string]interface{}, path string, item interface{}) (map[string]*SchemaOperation, []string) {
+	operations := make(map[string]*SchemaOperation)
+	fields, ok := stringMap(item)
+	if !ok {
+		return operations, []string{fmt.Sprintf("path %s is not an object", path)}
+	}
+	var problems []string
+	common := parseParameters(fields["parameters"])
+	for _, method := range schemaMethods {
+		value, exist := fields[method]
+		if !exist {
+			continue
+		}
+		op, ok := stringMap(value)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s is not an object", method, path))
+			continue
+		}
+		operation := &SchemaOperation{
+			OperationID:       scalarString(op["operationId"]),
+			Parameters:        mergeParameters(common, parseParameters(op["parameters"])),
+			RequestBodyFields: requestBodyFields(doc, fields, op),
+		}
+		if body, ok := stringMap(op["requestBody"]); ok {
+			operation.RequestBodyRequired, _ = body["required"].(bool)
+		}
+		responses, _ := stringMap(op["responses"])
+		if len(responses) == 0 {
+			problems = append(problems, fmt.Sprintf("%s %s: responses is required", method, path))
+		}
+		for code := range responses {
+			operation.Responses = append(operation.Responses, code)
+		}
+		sort.Strings(operation.Responses)
+		operations[method] = operation
+	}
+	return operations, problems
+}
+
+func parseParameters(value interface{}) []*SchemaParameter {
+	list, _ := value.([]interface{})
+	params := make([]*SchemaParameter, 0, len(list))
+	for _, item := range list {
+		fields, ok := stringMap(item)
+		if !ok {
+			continue
+		}
+		p := &SchemaParameter{
+			Name: scalarString(fields["name"]),
+			In:   scalarString(fields["in"]),
+		}
+		p.Required, _ = fields["required"].(bool)
+		p.Type = scalarString(fields["type"])
+		if schema, ok := stringMap(fields["schema"]); ok && p.Type == "" {
+			// OpenAPI 3 defines the type in schema
+			p.Type = scalarString(schema["type"])
+		}
+		if p.Name == "" {
+			// a reference is kept by its target
+			p.Name = scalarString(fields["$ref"])
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// requestBodyFields returns the required properties of the request body of OpenAPI 3 or the body parameter of OpenAPI 2
+func requestBodyFields(doc, item, op map[string]interface{}) []string {
+	var schemas []interface{}
+	if body, ok := stringMap(op["requestBody"]); ok {
+		content, _ := stringMap(body["content"])
+		for _, media := range content {
+			if m, ok := stringMap(media); ok {
+				schemas = append(schemas, m["schema"])
+			}
+		}
+	}
+	for _, value := range []interface{}{item["parameters"], op["parameters"]} {
+		list, _ := value.([]interface{})
+		for _, param := range list {
+			if fields, ok := stringMap(param); ok && scalarString(fields["in"]) == "body" {
+				schemas = append(schemas, fields["schema"])
+			}
+		}
+	}
+	required := make(map[string]bool)
+	for _, schema := range schemas {
+		fields, ok := stringMap(resolveRef(doc, schema))
+		if !ok {
+			continue
+		}
+		list, _ := fields["required"].([]interface{})
+		for _, name := range list {
+			required[scalarString(name)] = true
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// maxRefDepth limits the chain of references, so that a reference to itself does not loop
+const maxRefDepth = 8
+
+// resolveRef returns the target of a local reference like #/definitions/User, or the value itself if it is not a reference
+func resolveRef(doc map[string]interface{}, value interface{}) interface{} {
+	for i := 0; i < maxRefDepth; i++ {
+		fields, ok := stringMap(value)
+		if !ok {
+			return value
+		}
+		ref, ok := fields["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return value
+		}
+		var target interface{} = doc
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			m, ok := stringMap(target)
+			if !ok {
+				return nil
+			}
+			target = m[key]
+		}
+		value = target
+	}
+	return nil
+}
+
+// mergeParameters overrides the parameters of the path item by the ones of the operation with the same name and location
+func mergeParameters(common, own []*SchemaParameter) []*SchemaParameter {
+	merged := make([]*SchemaParameter, 0, len(common)+len(own))
+	for _, c := range common {
+		overridden := false
+		for _, o := range own {
+			if o.Name == c.Name && o.In == c.In {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, c)
+		}
+	}
+	return append(merged, own...)
+}
+
+// stringMap converts a decoded mapping to map[string]interface{}, the keys like status codes may be decoded as int
+func stringMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			converted[fmt.Sprint(k)] = v
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+// scalarString returns the decoded scalar as string, use scalarText for the values whose text matters, like versions
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// scalarText returns the text of the scalar at the path of keys in the YAML document, empty if it is not a scalar
+func scalarText(node *yaml.Node, keys ...string) string {
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return ""
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				value = node.Content[i+1]
+			}
+		}
+		if value == nil {
+			return ""
+		}
+		node = value
+	}
+	if node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+// AddValidatedSchema validates the schema as OpenAPI 2 or 3 before adding it to the service
+func (c *Client) AddValidatedSchema(microServiceID, schemaName, schemaInfo string) (*SchemaDocument, error) {
+	doc, err := ParseSchema([]byte(schemaInfo))
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", schemaName, err)
+	}
+	if err := c.AddSchemas(microServiceID, schemaName, schemaInfo); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// GetSchemaDocument returns the parsed schema of the service, ErrSchemaNotExists if the schema does not exist
+func (c *Client) GetSchemaDocument(microServiceID, schemaName string, opts ...CallOption) (*SchemaDocument, error) {
+	body, err := c.GetSchema(microServiceID, schemaName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var response discovery.GetSchemaResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, NewJSONException(err, string(body))
+	}
+	if response.Schema == "" {
+		return nil, ErrSchemaNotExists
+	}
+	return ParseSchema([]byte(response.Schema))
+}
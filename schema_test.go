@@ -0,0 +1,195 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+const swagger2 = `
+swagger: "2.0"
+info:
+  title: hello
+  version: 1.0.0
+basePath: /hello
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        type: string
+    get:
+      operationId: getUser
+      parameters:
+        - name: verbose
+          in: query
+          type: boolean
+      responses:
+        200:
+          description: ok
+`
+
+const openapi3 = `{
+  "openapi": "3.0.1",
+  "info": {"title": "hello", "version": "1.0.0"},
+  "paths": {
+    "/users": {
+      "post": {
+        "requestBody": {"required": true, "content": {}},
+        "responses": {"201": {"description": "created"}, "default": {"description": "error"}}
+      }
+    }
+  }
+}`
+
+func TestParseSchema(t *testing.T) {
+	doc, err := sc.ParseSchema([]byte(swagger2))
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0", doc.OpenAPIVersion)
+	assert.False(t, doc.IsOpenAPI3())
+	assert.Equal(t, "/hello", doc.BasePath)
+	op := doc.Paths["/users/{id}"]["get"]
+	assert.Equal(t, "getUser", op.OperationID)
+	assert.Equal(t, []*sc.SchemaParameter{{Name: "id", In: "path", Required: true, Type: "string"}, {Name: "verbose", In: "query", Type: "boolean"}}, op.Parameters)
+	assert.Equal(t, []string{"200"}, op.Responses)
+
+	doc, err = sc.ParseSchema([]byte(openapi3))
+	assert.NoError(t, err)
+	assert.True(t, doc.IsOpenAPI3())
+	op = doc.Paths["/users"]["post"]
+	assert.True(t, op.RequestBodyRequired)
+	assert.Equal(t, []string{"201", "default"}, op.Responses)
+
+	t.Run("unquoted versions", func(t *testing.T) {
+		doc, err := sc.ParseSchema([]byte("swagger: 2.0\ninfo: {title: a, version: 1.10}\npaths: {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, "2.0", doc.OpenAPIVersion)
+		assert.Equal(t, "1.10", doc.Version)
+		doc, err = sc.ParseSchema([]byte("openapi: 3.0\ninfo: {title: a, version: 1}\npaths: {}"))
+		assert.NoError(t, err)
+		assert.Equal(t, "3.0", doc.OpenAPIVersion)
+		assert.True(t, doc.IsOpenAPI3())
+	})
+	t.Run("invalid schemas", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"not yaml":        "{",
+			"empty":           "",
+			"not a map":       "- a",
+			"no version":      "info: {title: a, version: 1}\npaths: {}",
+			"no info":         "swagger: '2.0'\npaths: {}",
+			"no paths":        "openapi: 3.0.0\ninfo: {title: a, version: 1}",
+			"relative path":   "openapi: 3.0.0\ninfo: {title: a, version: 1}\npaths: {users: {}}",
+			"no responses":    "openapi: 3.0.0\ninfo: {title: a, version: 1}\npaths: {/users: {get: {}}}",
+			"unknown swagger": "swagger: '1.2'\ninfo: {title: a, version: 1}\npaths: {}",
+		} {
+			err := sc.ValidateSchema(content)
+			assert.True(t, errors.Is(err, sc.ErrInvalidSchema), name)
+		}
+	})
+}
+
+func TestClient_GetSchemaDocument(t *testing.T) {
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/v4/default/registry/microservices/sid/schemas/hello" {
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(`{"errorCode":"400016","errorMessage":"Schema does not exist."}`))
+			return
+		}
+		b, _ := json.Marshal(&discovery.GetSchemaResponse{Schema: swagger2})
+		writer.Write(b)
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	doc, err := c.GetSchemaDocument("sid", "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", doc.Title)
+
+	_, err = c.GetSchemaDocument("sid", "missing")
+	assert.Equal(t, sc.ErrSchemaNotExists, err)
+	_, err = c.GetSchema("sid", "missing")
+	assert.Equal(t, sc.ErrSchemaNotExists, err)
+
+	_, err = c.AddValidatedSchema("sid", "broken", "swagger: '2.0'")
+	assert.True(t, errors.Is(err, sc.ErrInvalidSchema))
+}
+
+func TestClient_ListSchemas(t *testing.T) {
+	schemas := map[string]*discovery.Schema{
+		"hello": {SchemaId: "hello", Summary: "s1", Schema: swagger2},
+		"stale": {SchemaId: "stale", Summary: "s2", Schema: openapi3},
+	}
+	var mutex sync.Mutex
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		const prefix = "/v4/default/registry/microservices/sid/schemas"
+		switch {
+		case request.Method == http.MethodGet && request.URL.Path == prefix:
+			response := &discovery.GetAllSchemaResponse{}
+			for _, id := range []string{"hello", "stale"} {
+				schema, ok := schemas[id]
+				if !ok {
+					continue
+				}
+				listed := &discovery.Schema{SchemaId: schema.SchemaId, Summary: schema.Summary}
+				if request.URL.Query().Get("withSchema") == "1" {
+					listed.Schema = schema.Schema
+				}
+				response.Schemas = append(response.Schemas, listed)
+			}
+			b, _ := json.Marshal(response)
+			writer.Write(b)
+		case request.Method == http.MethodDelete && strings.HasPrefix(request.URL.Path, prefix+"/"):
+			id := strings.TrimPrefix(request.URL.Path, prefix+"/")
+			if _, ok := schemas[id]; !ok {
+				writer.WriteHeader(http.StatusBadRequest)
+				writer.Write([]byte(`{"errorCode":"400016","errorMessage":"Schema does not exist."}`))
+				return
+			}
+			delete(schemas, id)
+		default:
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(`{"errorCode":"400012","errorMessage":"Micro-service does not exist."}`))
+		}
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	list, err := c.ListSchemas("sid")
+	assert.NoError(t, err)
+	assert.Equal(t, []*discovery.Schema{{SchemaId: "hello", Summary: "s1"}, {SchemaId: "stale", Summary: "s2"}}, list)
+	list, err = c.ListSchemas("sid", sc.WithSchemaContent())
+	assert.NoError(t, err)
+	assert.Equal(t, swagger2, list[0].Schema)
+	_, err = c.ListSchemas("missing")
+	assert.Equal(t, sc.ErrMicroServiceNotExists, err)
+
+	t.Run("delete the stale schema", func(t *testing.T) {
+		assert.NoError(t, c.DeleteSchema("sid", "stale"))
+		assert.Equal(t, sc.ErrSchemaNotExists, c.DeleteSchema("sid", "stale"))
+		list, err := c.ListSchemas("sid")
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+	})
+}
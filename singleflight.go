@@ -0,0 +1,86 @@
+package sc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// flight is a request in flight shared by the identical requests
+type flight struct {
+	wg   sync.WaitGroup
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// flightGroup collapses the identical requests in flight into one
+type flightGroup struct {
+	mutex   sync.Mutex
+	flights map[string]*flight
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// do calls fn once for the requests with the same key in flight, each caller gets its own copy of the response
+func (g *flightGroup) do(key string, fn func() (*http.Response, error)) (*http.Response, error) {
+	g.mutex.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mutex.Unlock()
+		f.wg.Wait()
+		return f.response()
+	}
+	f := &flight{}
+	f.wg.Add(1)
+	g.flights[key] = f
+	g.mutex.Unlock()
+
+	f.resp, f.err = fn()
+	if f.err == nil && f.resp != nil {
+		// the body is read once and replayed to every caller
+		f.body, f.err = ioutil.ReadAll(f.resp.Body)
+		f.resp.Body.Close()
+		if f.err != nil {
+			f.err = NewIOException(f.err)
+		}
+	}
+	g.mutex.Lock()
+	delete(g.flights, key)
+	g.mutex.Unlock()
+	f.wg.Done()
+	return f.response()
+}
+
+func (f *flight) response() (*http.Response, error) {
+	if f.err != nil || f.resp == nil {
+		return f.resp, f.err
+	}
+	copied := *f.resp
+	copied.Header = f.resp.Header.Clone()
+	copied.Body = ioutil.NopCloser(bytes.NewReader(f.body))
+	return &copied, nil
+}
+
+// readDo makes a read-only request, the identical requests in flight share one request
+// if Options.DeduplicateReads is set. The requests are identical if they have the same method,
+// path and query including the revision, headers like X-ConsumerId and body,
+// the address is ignored unless it is specified by WithAddress.
+func (c *Client) readDo(method string, rawURL string, headers http.Header, body []byte, copts *CallOptions) (*http.Response, error) {
+	if c.flights == nil {
+		return c.hedgedDo(method, rawURL, headers, body, copts)
+	}
+	target := rawURL
+	if u, err := url.Parse(rawURL); err == nil && (copts == nil || copts.Address == "") {
+		target = u.RequestURI()
+	}
+	// the keys of a map are printed in sorted order
+	key := fmt.Sprintf("%s %s %v %s", method, target, headers, body)
+	return c.flights.do(key, func() (*http.Response, error) {
+		return c.hedgedDo(method, rawURL, headers, body, copts)
+	})
+}
@@ -0,0 +1,84 @@
+package sc_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestClient_DeduplicateReads(t *testing.T) {
+	var calls int32
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		writer.Header().Set(sc.HeaderRevision, "r1")
+		writer.Write([]byte(`{"instances":[{"instanceId":"1"}]}`))
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints:        []string{scServer.Listener.Addr().String()},
+		DeduplicateReads: true,
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	find := func(n int, consumers []string, opts ...sc.CallOption) {
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(consumer string) {
+				defer wg.Done()
+				result, err := c.FindInstances(consumer, "default", "provider", opts...)
+				assert.NoError(t, err)
+				assert.Equal(t, "1", result.Instances[0].InstanceId)
+				assert.Equal(t, "r1", result.Revision)
+			}(consumers[i%len(consumers)])
+		}
+		wg.Wait()
+	}
+	find(10, []string{"consumer"})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	t.Run("requests of different consumers are not shared", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		find(10, []string{"consumer1", "consumer2"})
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+	t.Run("requests of different revisions are not shared", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		var wg sync.WaitGroup
+		for _, rev := range []string{"r0", "r0", "r2"} {
+			wg.Add(1)
+			go func(rev string) {
+				defer wg.Done()
+				c.FindInstances("consumer", "default", "provider", sc.WithRevision(rev))
+			}(rev)
+		}
+		wg.Wait()
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+	t.Run("requests to different specified addresses are not shared", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		port := scServer.Listener.Addr().(*net.TCPAddr).Port
+		var wg sync.WaitGroup
+		for _, host := range []string{"127.0.0.1", "127.0.0.1", "localhost"} {
+			wg.Add(1)
+			go func(address string) {
+				defer wg.Done()
+				c.FindInstances("consumer", "default", "provider", sc.WithAddress(address))
+			}(fmt.Sprintf("%s:%d", host, port))
+		}
+		wg.Wait()
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
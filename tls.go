@@ -0,0 +1,200 @@
+package sc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/go-chassis/openlog"
+)
+
+// DefaultTLSCheckInterval is the default interval the certificate files are checked for changes
+const DefaultTLSCheckInterval = 10 * time.Second
+
+// TLSOptions is the tls settings read from files
+type TLSOptions struct {
+	// CAFile verifies the certificate of service-center, the system roots are used if it is empty
+	CAFile string
+	// CertFile and KeyFile are the client certificate, they are optional
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify skips verifying the certificate of service-center, it should only be used for testing
+	InsecureSkipVerify bool
+	// ServerName is used to verify the hostname of service-center, the host or IP of the endpoint is verified if it is empty
+	ServerName   string
+	MinVersion   uint16
+	MaxVersion   uint16
+	CipherSuites []uint16
+	// CheckInterval is the interval the files are checked for changes, default is DefaultTLSCheckInterval
+	CheckInterval time.Duration
+}
+
+// buildTLSConfig builds TLSConfig from TLS and enables ssl, it returns the reloader of the certificate files
+func (o *Options) buildTLSConfig() (*certReloader, error) {
+	if o.TLS == nil || o.TLSConfig != nil {
+		return nil, nil
+	}
+	r := &certReloader{opt: *o.TLS}
+	if r.opt.CheckInterval <= 0 {
+		r.opt.CheckInterval = DefaultTLSCheckInterval
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	o.TLSConfig = &tls.Config{
+		ServerName:           o.TLS.ServerName,
+		MinVersion:           o.TLS.MinVersion,
+		MaxVersion:           o.TLS.MaxVersion,
+		CipherSuites:         o.TLS.CipherSuites,
+		RootCAs:              r.roots,
+		GetClientCertificate: r.clientCertificate,
+		InsecureSkipVerify:   o.TLS.InsecureSkipVerify, // #nosec G402
+	}
+	o.EnableSSL = true
+	return r, nil
+}
+
+// reloadRoots rebuilds the http client and the websocket dialer if the CA file changes,
+// because RootCAs of a tls.Config in use can not be replaced
+func (c *Client) reloadRoots() {
+	c.cfgMutex.RLock()
+	certs, opt, signRequest := c.certs, c.opt, c.signRequest
+	c.cfgMutex.RUnlock()
+	if certs == nil {
+		return
+	}
+	_, roots := certs.current()
+	if roots == opt.TLSConfig.RootCAs {
+		return
+	}
+	former := opt.TLSConfig
+	opt.TLSConfig = former.Clone()
+	opt.TLSConfig.RootCAs = roots
+	options := c.buildClientOptions(opt)
+	// keep the token cache of the former client
+	options.SignRequest = signRequest
+	client, err := httpclient.New(options)
+	if err != nil {
+		openlog.Warn(fmt.Sprintf("rebuild the client with the reloaded CA failed: %s", err.Error()))
+		return
+	}
+	wsDialer, _ := newWebsocketDialer(opt)
+	c.cfgMutex.Lock()
+	defer c.cfgMutex.Unlock()
+	if c.certs != certs || c.opt.TLSConfig != former {
+		// rebuilt by others or reset
+		return
+	}
+	c.opt.TLSConfig = opt.TLSConfig
+	c.client = client
+	c.wsDialer = wsDialer
+}
+
+// certReloader holds the certificates read from files, and reads them again if the files change.
+// It is shared by the http client and the websocket dialer through the same tls.Config.
+type certReloader struct {
+	opt   TLSOptions
+	mutex sync.RWMutex
+	// nextCheck is the time the files are checked again
+	nextCheck time.Time
+	modTime   map[string]time.Time
+	cert      *tls.Certificate
+	roots     *x509.CertPool
+}
+
+// changed returns whether any of the files is modified since last reload
+func (r *certReloader) changed() bool {
+	for _, f := range []string{r.opt.CAFile, r.opt.CertFile, r.opt.KeyFile} {
+		if f == "" {
+			continue
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTime[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) reload() error {
+	modTime := make(map[string]time.Time)
+	for _, f := range []string{r.opt.CAFile, r.opt.CertFile, r.opt.KeyFile} {
+		if f == "" {
+			continue
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			return NewIOException(err, f)
+		}
+		modTime[f] = info.ModTime()
+	}
+	var roots *x509.CertPool
+	if r.opt.CAFile != "" {
+		pem, err := ioutil.ReadFile(r.opt.CAFile)
+		if err != nil {
+			return NewIOException(err, r.opt.CAFile)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate is found in %s", r.opt.CAFile)
+		}
+	}
+	var cert *tls.Certificate
+	if r.opt.CertFile != "" || r.opt.KeyFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.opt.CertFile, r.opt.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load certificate %s failed: %s", r.opt.CertFile, err.Error())
+		}
+		cert = &pair
+	}
+	r.roots = roots
+	r.cert = cert
+	r.modTime = modTime
+	r.nextCheck = time.Now().Add(r.opt.CheckInterval)
+	return nil
+}
+
+// current returns the certificates, they are reloaded first if the files change,
+// the files are checked at most once per CheckInterval.
+// If the changed files are not valid, for example, only one of them is written, the former ones are kept
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	now := time.Now()
+	r.mutex.RLock()
+	if now.Before(r.nextCheck) {
+		defer r.mutex.RUnlock()
+		return r.cert, r.roots
+	}
+	r.mutex.RUnlock()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if now.Before(r.nextCheck) {
+		// checked by others
+		return r.cert, r.roots
+	}
+	r.nextCheck = now.Add(r.opt.CheckInterval)
+	if r.changed() {
+		if err := r.reload(); err != nil {
+			openlog.Warn(fmt.Sprintf("reload certificates failed, keep the former ones: %s", err.Error()))
+		} else {
+			openlog.Info("certificates are reloaded")
+		}
+	}
+	return r.cert, r.roots
+}
+
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, _ := r.current()
+	if cert == nil {
+		// no client certificate is sent
+		return &tls.Certificate{}, nil
+	}
+	return cert, nil
+}
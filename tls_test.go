@@ -0,0 +1,107 @@
+package sc_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+// selfSignedPEM generates a certificate not trusted by anyone
+func selfSignedPEM(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "untrusted"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestNewClient_TLSReload(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(`{"instances":[]}`))
+	}))
+	defer s.Close()
+	trusted := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, ioutil.WriteFile(caFile, selfSignedPEM(t), 0600))
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{s.Listener.Addr().String()},
+		TLS:       &sc.TLSOptions{CAFile: caFile, CheckInterval: 10 * time.Millisecond},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+	_, err = c.Health()
+	assert.Error(t, err)
+
+	t.Run("renew the ca, it is used without recreating the client", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(caFile, trusted, 0600))
+		future := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(caFile, future, future))
+		assert.Eventually(t, func() bool {
+			_, err = c.Health()
+			return err == nil
+		}, time.Second, 20*time.Millisecond)
+	})
+	t.Run("a broken ca is ignored, the former one is kept", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(caFile, []byte("broken"), 0600))
+		future := time.Now().Add(2 * time.Minute)
+		assert.NoError(t, os.Chtimes(caFile, future, future))
+		time.Sleep(20 * time.Millisecond)
+		_, err = c.Health()
+		assert.NoError(t, err)
+	})
+	t.Run("the hostname is verified", func(t *testing.T) {
+		trustedFile := filepath.Join(t.TempDir(), "ca.crt")
+		assert.NoError(t, ioutil.WriteFile(trustedFile, trusted, 0600))
+		c, err := sc.NewClient(sc.Options{
+			Endpoints: []string{s.Listener.Addr().String()},
+			TLS:       &sc.TLSOptions{CAFile: trustedFile, ServerName: "other.com"},
+		})
+		assert.NoError(t, err)
+		defer c.Close()
+		_, err = c.Health()
+		assert.Error(t, err)
+	})
+	t.Run("skip the verification explicitly", func(t *testing.T) {
+		untrusted := filepath.Join(t.TempDir(), "ca.crt")
+		assert.NoError(t, ioutil.WriteFile(untrusted, selfSignedPEM(t), 0600))
+		c, err := sc.NewClient(sc.Options{
+			Endpoints: []string{s.Listener.Addr().String()},
+			TLS:       &sc.TLSOptions{CAFile: untrusted, InsecureSkipVerify: true},
+		})
+		assert.NoError(t, err)
+		defer c.Close()
+		_, err = c.Health()
+		assert.NoError(t, err)
+	})
+	t.Run("missing files fail the creation", func(t *testing.T) {
+		_, err := sc.NewClient(sc.Options{
+			Endpoints: []string{s.Listener.Addr().String()},
+			TLS:       &sc.TLSOptions{CAFile: filepath.Join(t.TempDir(), "none.crt")},
+		})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,110 @@
+package sc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+// ErrRevisionConflict means the resource is modified by others since the given revision
+var ErrRevisionConflict = errors.New("resource is modified since the revision")
+
+// UpdateMicroServiceInstance updates all the fields of instance, like endpoints and hostname,
+// by registering it again with the same instance id
+func (c *Client) UpdateMicroServiceInstance(microServiceInstance *discovery.MicroServiceInstance) (bool, error) {
+	if microServiceInstance == nil || microServiceInstance.ServiceId == "" || microServiceInstance.InstanceId == "" {
+		return false, errors.New("invalid request parameter")
+	}
+	instanceID, err := c.RegisterMicroServiceInstance(microServiceInstance)
+	if err != nil {
+		return false, err
+	}
+	if instanceID != microServiceInstance.InstanceId {
+		return false, fmt.Errorf("update instance failed, MicroServiceId/MicroServiceInstanceId: %s/%s, registered as a new instance %s",
+			microServiceInstance.ServiceId, microServiceInstance.InstanceId, instanceID)
+	}
+	return true, nil
+}
+
+// UpdateMicroServiceSchemas replaces all the schemas of the service, the schema list of the service is updated as well
+func (c *Client) UpdateMicroServiceSchemas(microServiceID string, schemas []*discovery.Schema) (bool, error) {
+	if microServiceID == "" {
+		return false, errors.New("invalid micro service ID")
+	}
+	request := &discovery.ModifySchemasRequest{
+		ServiceId: microServiceID,
+		Schemas:   schemas,
+	}
+	url := c.formatURL(fmt.Sprintf("%s%s/%s%s", MSAPIPath, MicroservicePath, microServiceID, SchemaPath), nil, nil)
+	body, err := json.Marshal(request)
+	if err != nil {
+		return false, NewJSONException(err, string(body))
+	}
+	resp, err := c.httpDo("POST", url, nil, body)
+	if err != nil {
+		return false, err
+	}
+	if resp == nil {
+		return false, fmt.Errorf("UpdateMicroServiceSchemas failed, response is empty, MicroServiceId: %s", microServiceID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return false, NewIOException(err)
+		}
+		return false, NewCommonException("result: %d %s", resp.StatusCode, string(body))
+	}
+	return true, nil
+}
+
+// UpdateMicroServicePropertiesIfUnmodified replaces the properties of the service if it is not modified since revision,
+// the revision is the ModTimestamp of the service returned by GetMicroService.
+// If the service is modified by others, ErrRevisionConflict is returned, the caller should get the service and try again.
+// Service-center has no conditional update, so the revision is checked right before the update
+// and the service is read again after it, a conflicting update made by others in between is reported
+// as ErrRevisionConflict, but it may already be overwritten.
+// The description and paths of a service can not be changed after it is registered,
+// use UpdateMicroServiceSchemas to change the schemas.
+func (c *Client) UpdateMicroServicePropertiesIfUnmodified(microServiceID, revision string, properties map[string]string) (bool, error) {
+	if properties == nil {
+		return false, errors.New("invalid request parameter")
+	}
+	current, err := c.GetMicroService(microServiceID)
+	if err != nil {
+		return false, err
+	}
+	if current.ModTimestamp != revision {
+		return false, ErrRevisionConflict
+	}
+	if sameProperties(properties, current.Properties) {
+		return true, nil
+	}
+	if _, err := c.UpdateMicroServiceProperties(microServiceID, &discovery.MicroService{Properties: properties}); err != nil {
+		return false, err
+	}
+	updated, err := c.GetMicroService(microServiceID)
+	if err != nil {
+		return false, err
+	}
+	if !sameProperties(properties, updated.Properties) {
+		return false, ErrRevisionConflict
+	}
+	return true, nil
+}
+
+// sameProperties returns whether the properties are the same, nil equals to empty
+func sameProperties(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package sc_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+	"github.com/go-chassis/sc-client/internal/scfake"
+)
+
+func TestClient_UpdateMicroService(t *testing.T) {
+	s := scfake.New()
+	defer s.Close()
+	sid := s.AddService(&discovery.MicroService{AppId: "default", ServiceName: "updateService", Version: "0.0.1"})
+	c, err := sc.NewClient(sc.Options{Endpoints: []string{s.Addr()}})
+	assert.NoError(t, err)
+
+	t.Run("update with the latest revision, should success", func(t *testing.T) {
+		ms, err := c.GetMicroService(sid)
+		assert.NoError(t, err)
+		ok, err := c.UpdateMicroServicePropertiesIfUnmodified(sid, ms.ModTimestamp, map[string]string{"owner": "a"})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, map[string]string{"owner": "a"}, s.Services[sid].MicroService.Properties)
+	})
+	t.Run("update with a stale revision, should return conflict", func(t *testing.T) {
+		_, err := c.UpdateMicroServicePropertiesIfUnmodified(sid, "stale", map[string]string{"owner": "b"})
+		assert.Equal(t, sc.ErrRevisionConflict, err)
+		assert.Equal(t, map[string]string{"owner": "a"}, s.Services[sid].MicroService.Properties)
+	})
+	t.Run("updated by others during the update, should return conflict", func(t *testing.T) {
+		serve := s.Config.Handler
+		s.Config.Handler = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			serve.ServeHTTP(writer, request)
+			if request.Method == http.MethodPut && strings.HasSuffix(request.URL.Path, "/properties") {
+				s.Lock()
+				s.Services[sid].MicroService.Properties = map[string]string{"owner": "others"}
+				s.Unlock()
+			}
+		})
+		defer func() { s.Config.Handler = serve }()
+		ms, err := c.GetMicroService(sid)
+		assert.NoError(t, err)
+		_, err = c.UpdateMicroServicePropertiesIfUnmodified(sid, ms.ModTimestamp, map[string]string{"owner": "c"})
+		assert.Equal(t, sc.ErrRevisionConflict, err)
+	})
+	t.Run("update schemas, should replace all the schemas", func(t *testing.T) {
+		ok, err := c.UpdateMicroServiceSchemas(sid, []*discovery.Schema{
+			{SchemaId: "hello", Schema: "swagger: '2.0'", Summary: "s1"},
+			{SchemaId: "world", Schema: "swagger: '2.0'", Summary: "s2"},
+		})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = c.UpdateMicroServiceSchemas(sid, []*discovery.Schema{
+			{SchemaId: "world", Schema: "swagger: '2.0'", Summary: "s3"},
+		})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ms, err := c.GetMicroService(sid)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"world"}, ms.Schemas)
+		assert.Equal(t, "s3", s.Services[sid].Schemas["world"].Summary)
+		_, err = c.UpdateMicroServiceSchemas("", nil)
+		assert.Error(t, err)
+	})
+	t.Run("update instance endpoints, should keep the instance id", func(t *testing.T) {
+		instance := &discovery.MicroServiceInstance{
+			ServiceId: sid,
+			Endpoints: []string{"rest://127.0.0.1:3000"},
+			HostName:  "host",
+			Status:    sc.MSInstanceUP,
+		}
+		iid, err := c.RegisterMicroServiceInstance(instance)
+		assert.NoError(t, err)
+		instance.InstanceId = iid
+		instance.Endpoints = []string{"rest://127.0.0.1:3001"}
+		ok, err := c.UpdateMicroServiceInstance(instance)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		instances, err := c.GetMicroServiceInstances(sid, sid)
+		assert.NoError(t, err)
+		assert.Len(t, instances, 1)
+		assert.Equal(t, []string{"rest://127.0.0.1:3001"}, instances[0].Endpoints)
+	})
+}
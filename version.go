@@ -0,0 +1,201 @@
+package sc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+var (
+	// ErrInvalidVersion means the version is not 1 to 4 dot separated numbers like 1.0.0
+	ErrInvalidVersion = errors.New("invalid version")
+	// ErrInvalidVersionRule means the rule is not an exact version, latest, x+ or a range x-y
+	ErrInvalidVersionRule = errors.New("invalid version rule")
+)
+
+// maxVersionPart is the max value of a part of version accepted by service center
+const maxVersionPart = 32767
+
+// the kinds of version rules
+const (
+	// VersionRuleExact matches a version, like 1.0.0
+	VersionRuleExact = "exact"
+	// VersionRuleLatest matches the latest version, the rule is latest
+	VersionRuleLatest = "latest"
+	// VersionRuleAtLeast matches the version and the later ones, like 1.0.0+
+	VersionRuleAtLeast = "atLeast"
+	// VersionRuleRange matches the versions from the first one to the one before the second one, like 1.0.0-2.0.0
+	VersionRuleRange = "range"
+)
+
+// Version is a version of service, 1 to 4 dot separated numbers, the missing parts are 0
+type Version struct {
+	parts [4]int
+	raw   string
+}
+
+// ParseVersion parses and validates the version in the format accepted by service center
+func ParseVersion(s string) (Version, error) {
+	fields := strings.Split(s, ".")
+	if len(fields) > 4 {
+		return Version{}, fmt.Errorf("%w: %q has more than 4 parts", ErrInvalidVersion, s)
+	}
+	v := Version{raw: s}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || n > maxVersionPart || strings.HasPrefix(f, "+") {
+			return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+		}
+		v.parts[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if v is before, the same as or after other, 1.0 is the same as 1.0.0
+func (v Version) Compare(other Version) int {
+	for i := range v.parts {
+		if v.parts[i] != other.parts[i] {
+			if v.parts[i] < other.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return v.raw
+}
+
+// VersionRule matches the versions of a provider, String returns the rule sent to service center.
+// The zero VersionRule is not valid, it matches nothing and is rejected by FindInstances.
+type VersionRule struct {
+	kind string
+	from Version
+	to   Version
+}
+
+var (
+	// LatestVersion matches the latest version
+	LatestVersion = VersionRule{kind: VersionRuleLatest}
+	// AllVersions matches all the versions, it is the rule of FindInstances by default
+	AllVersions = VersionRule{kind: VersionRuleAtLeast, from: Version{raw: "0"}}
+)
+
+// ExactVersion matches the version
+func ExactVersion(v Version) VersionRule {
+	return VersionRule{kind: VersionRuleExact, from: v}
+}
+
+// AtLeastVersion matches the version and the later ones
+func AtLeastVersion(v Version) VersionRule {
+	return VersionRule{kind: VersionRuleAtLeast, from: v}
+}
+
+// VersionRange matches the versions from "from" to the one before "to"
+func VersionRange(from, to Version) (VersionRule, error) {
+	if from.Compare(to) >= 0 {
+		return VersionRule{}, fmt.Errorf("%w: %s is not before %s", ErrInvalidVersionRule, from, to)
+	}
+	return VersionRule{kind: VersionRuleRange, from: from, to: to}, nil
+}
+
+// ParseVersionRule parses the rule like 1.0.0, latest, 1.0.0+ and 1.0.0-2.0.0
+func ParseVersionRule(s string) (VersionRule, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == VersionRuleLatest:
+		return LatestVersion, nil
+	case strings.HasSuffix(s, "+"):
+		v, err := ParseVersion(strings.TrimSuffix(s, "+"))
+		if err != nil {
+			return VersionRule{}, fmt.Errorf("%w: %s", ErrInvalidVersionRule, err.Error())
+		}
+		return AtLeastVersion(v), nil
+	case strings.Contains(s, "-"):
+		bounds := strings.SplitN(s, "-", 2)
+		from, err := ParseVersion(bounds[0])
+		if err != nil {
+			return VersionRule{}, fmt.Errorf("%w: %s", ErrInvalidVersionRule, err.Error())
+		}
+		to, err := ParseVersion(bounds[1])
+		if err != nil {
+			return VersionRule{}, fmt.Errorf("%w: %s", ErrInvalidVersionRule, err.Error())
+		}
+		return VersionRange(from, to)
+	}
+	v, err := ParseVersion(s)
+	if err != nil {
+		return VersionRule{}, fmt.Errorf("%w: %s", ErrInvalidVersionRule, err.Error())
+	}
+	return ExactVersion(v), nil
+}
+
+// Kind returns the kind of the rule, like VersionRuleExact
+func (r VersionRule) Kind() string {
+	return r.kind
+}
+
+func (r VersionRule) String() string {
+	switch r.kind {
+	case VersionRuleLatest:
+		return VersionRuleLatest
+	case VersionRuleAtLeast:
+		return r.from.String() + "+"
+	case VersionRuleRange:
+		return r.from.String() + "-" + r.to.String()
+	}
+	return r.from.String()
+}
+
+// IsZero returns whether the rule is the zero VersionRule
+func (r VersionRule) IsZero() bool {
+	return r.kind == ""
+}
+
+// Match returns whether the version matches the rule, an invalid version matches nothing.
+// Every version matches latest, which is resolved among a list of versions by FilterInstancesByVersion.
+func (r VersionRule) Match(version string) bool {
+	v, err := ParseVersion(version)
+	if err != nil || r.IsZero() {
+		return false
+	}
+	switch r.kind {
+	case VersionRuleLatest:
+		return true
+	case VersionRuleAtLeast:
+		return v.Compare(r.from) >= 0
+	case VersionRuleRange:
+		return v.Compare(r.from) >= 0 && v.Compare(r.to) < 0
+	}
+	return v.Compare(r.from) == 0
+}
+
+// FilterInstancesByVersion returns the instances whose versions match the rule,
+// for latest, the instances of the latest version among them are returned, the nil instances are skipped
+func FilterInstancesByVersion(instances []*discovery.MicroServiceInstance, rule VersionRule) []*discovery.MicroServiceInstance {
+	matched := make([]*discovery.MicroServiceInstance, 0, len(instances))
+	var latest Version
+	for _, instance := range instances {
+		if instance == nil || !rule.Match(instance.Version) {
+			continue
+		}
+		if rule.kind == VersionRuleLatest {
+			v, _ := ParseVersion(instance.Version)
+			switch c := v.Compare(latest); {
+			case len(matched) == 0 || c > 0:
+				latest = v
+				matched = append(matched[:0], instance)
+				continue
+			case c < 0:
+				continue
+			}
+		}
+		matched = append(matched, instance)
+	}
+	return matched
+}
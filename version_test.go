@@ -0,0 +1,101 @@
+package sc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/sc-client"
+)
+
+func TestParseVersionRule(t *testing.T) {
+	for rule, kind := range map[string]string{
+		"1.0.0":       sc.VersionRuleExact,
+		"1":           sc.VersionRuleExact,
+		"latest":      sc.VersionRuleLatest,
+		"0+":          sc.VersionRuleAtLeast,
+		"1.2.3.4+":    sc.VersionRuleAtLeast,
+		"1.0.0-2.0.0": sc.VersionRuleRange,
+	} {
+		r, err := sc.ParseVersionRule(rule)
+		assert.NoError(t, err, rule)
+		assert.Equal(t, kind, r.Kind(), rule)
+		assert.Equal(t, rule, r.String())
+	}
+	for _, rule := range []string{"", "v1", "1.0.0.0.0", "1.a", "32768", "2.0.0-1.0.0", "1.0.0-", "+"} {
+		_, err := sc.ParseVersionRule(rule)
+		assert.True(t, errors.Is(err, sc.ErrInvalidVersionRule), rule)
+	}
+
+	r, _ := sc.ParseVersionRule("1.0-2.0")
+	assert.True(t, r.Match("1.0.0"))
+	assert.True(t, r.Match("1.10.1"))
+	assert.False(t, r.Match("2.0.0"))
+	assert.False(t, r.Match("0.9"))
+	assert.False(t, r.Match("invalid"))
+	assert.Equal(t, "0+", sc.AllVersions.String())
+}
+
+func TestFilterInstancesByVersion(t *testing.T) {
+	instances := []*discovery.MicroServiceInstance{
+		{InstanceId: "a", Version: "1.2.0"},
+		{InstanceId: "b", Version: "1.10.0"},
+		{InstanceId: "c", Version: "1.10"},
+		{InstanceId: "d", Version: "1.9.0"},
+		{InstanceId: "e"},
+		nil,
+	}
+	ids := func(instances []*discovery.MicroServiceInstance) []string {
+		var ids []string
+		for _, i := range instances {
+			ids = append(ids, i.InstanceId)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"b", "c"}, ids(sc.FilterInstancesByVersion(instances, sc.LatestVersion)))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids(sc.FilterInstancesByVersion(instances, sc.AllVersions)))
+	v, _ := sc.ParseVersion("1.9")
+	assert.Equal(t, []string{"d"}, ids(sc.FilterInstancesByVersion(instances, sc.ExactVersion(v))))
+	assert.Empty(t, sc.FilterInstancesByVersion(instances, sc.VersionRule{}))
+}
+
+func TestClient_FindInstancesVersionRule(t *testing.T) {
+	var mutex sync.Mutex
+	var versions []string
+	scServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		versions = append(versions, request.URL.Query().Get("version"))
+		mutex.Unlock()
+		b, _ := json.Marshal(&discovery.GetInstancesResponse{})
+		writer.Write(b)
+	}))
+	defer scServer.Close()
+
+	c, err := sc.NewClient(sc.Options{
+		Endpoints: []string{scServer.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.FindInstances("consumer", "default", "provider")
+	assert.NoError(t, err)
+	_, err = c.FindInstances("consumer", "default", "provider", sc.WithVersionRule(sc.LatestVersion))
+	assert.NoError(t, err)
+	_, err = c.FindMicroServiceInstances("consumer", "default", "provider", "0%2B")
+	assert.NoError(t, err)
+	_, err = c.FindMicroServiceInstances("consumer", "default", "provider", "1.0.0-2.0.0")
+	assert.NoError(t, err)
+	_, err = c.FindMicroServiceInstances("consumer", "default", "provider", "1.0.0+")
+	assert.NoError(t, err)
+	_, err = c.FindInstances("consumer", "default", "provider", sc.WithVersionRule(sc.VersionRule{}))
+	assert.True(t, errors.Is(err, sc.ErrInvalidVersionRule))
+	mutex.Lock()
+	assert.Equal(t, []string{"0+", "latest", "0+", "1.0.0-2.0.0", "1.0.0+"}, versions)
+	mutex.Unlock()
+}